@@ -15,12 +15,14 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Args contains the arguments which have been used to invoke GoUp
@@ -43,14 +45,33 @@ type Args struct {
 	// Targets contains the different build targets, e.g. gomobile/android or gomobile/ios
 	Targets []string
 
-	// ClearWorkspace does not reuse the workspace
-	ClearWorkspace bool
+	// DryRun only prints what a command would do, without modifying anything
+	DryRun bool
+
+	// StrictDeps fails the build, if the local modules require conflicting versions of a dependency
+	StrictDeps bool
+
+	// Timeout is the deadline for the entire build, 0 means no limit
+	Timeout time.Duration
+
+	// Variables are set by -var KEY=VALUE and override all other variables
+	Variables map[string]string
+
+	// Profile is the name of the profile which overlays the build section, if not empty
+	Profile string
+
+	// Command is the executed command, e.g. build or schema
+	Command string
+
+	// CommandArgs are the remaining arguments after the command, which are parsed by the command itself
+	CommandArgs []string
+
+	// buildFile is the unresolved -buildFile flag, which is relative to BaseDir
+	buildFile string
 }
 
-// Evaluate reads all flags and parses them into the receiver.
-// On failures or if help requested it may also print to the console
-// and exit
-func (a *Args) Evaluate() {
+// NewArgs returns the defaults of all options
+func NewArgs() *Args {
 	defaultHome, err := os.UserHomeDir()
 	if err != nil {
 		defaultHome = "/"
@@ -62,44 +83,162 @@ func (a *Args) Evaluate() {
 		defaultHome = overriddenDefaultHome
 	}
 
-	baseDir := flag.String("dir", CWD(), "Use a custom directory to resolve relative paths from "+goup+".yml.")
-	buildFile := flag.String("buildFile", "./"+goup+".yaml", "Use a build file to load.")
-	homeDir := flag.String("home", defaultHome, "Use this as the home directory, where "+goUp+" holds toolchains, projects and workspaces.")
-	logLevel := flag.Int("loglevel", int(Error), "The LogLevel determines what is printed into the console. 0=Debug, 1=Info, 2=Warn, 3=Error")
-	resourcesURL := flag.String("resources", defaultResourcesURL, "XML which describes downloadable toolchains")
-	targets := flag.String("targets", "all", "The targets to build, e.g. gomobile/android or gomobile/ios. Can be concated by :")
-
-	showVersion := flag.Bool("version", false, "Shows the version")
-	showHelp := flag.Bool("help", false, "Shows this help")
-	doReset := flag.Bool("reset", false, "Performs a reset, delete the home directory and exits")
-	doClean := flag.Bool("clean", false, "Removes the project workspace, but keeps toolchains.")
-
-	flag.Parse()
-	if *showHelp {
-		flag.PrintDefaults()
-		os.Exit(0)
+	return &Args{
+		BaseDir:      Path(CWD()),
+		HomeDir:      Path(defaultHome),
+		LogLevel:     Error,
+		ResourcesURL: defaultResourcesURL,
+		Targets:      []string{"all"},
+		Variables:    make(map[string]string),
+		buildFile:    "./" + goup + ".yaml",
 	}
+}
+
+// variableFlags collects repeated -var KEY=VALUE flags
+type variableFlags map[string]string
 
-	if *showVersion {
-		fmt.Println(goUp + " " + version)
-		os.Exit(0)
+func (v variableFlags) String() string {
+	tmp := make([]string, 0)
+	for k, val := range v {
+		tmp = append(tmp, k+"="+val)
 	}
+	return strings.Join(tmp, " ")
+}
 
-	a.BaseDir = Path(*baseDir)
-	a.BuildFile = Path(*buildFile).Resolve(a.BaseDir)
-	a.HomeDir = Path(*homeDir)
-	a.LogLevel = LogLevel(*logLevel)
-	a.ResourcesURL = *resourcesURL
-	a.Targets = strings.Split(*targets, ":")
-	a.ClearWorkspace = *doClean
+// Set parses KEY=VALUE, the value may contain further = chars
+func (v variableFlags) Set(str string) error {
+	pair := strings.SplitN(str, "=", 2)
+	if len(pair) != 2 || !isVarName(pair[0]) {
+		return fmt.Errorf("expected KEY=VALUE but got '%s'", str)
+	}
+	v[pair[0]] = pair[1]
+	return nil
+}
+
+// targetFlags parses the : separated targets
+type targetFlags []string
+
+func (t *targetFlags) String() string {
+	return strings.Join(*t, ":")
+}
+
+// Set replaces the targets
+func (t *targetFlags) Set(str string) error {
+	*t = strings.Split(str, ":")
+	return nil
+}
+
+// errUsage is returned, if the command line could not be parsed. The problem has already been printed.
+var errUsage = errors.New("invalid usage")
+
+// Evaluate determines the command and its arguments. Either a command is given with its own flags,
+// e.g. goup build -targets gomobile/ios, or the legacy form without a command, as used by older wrapper scripts,
+// e.g. goup -dir . -loglevel 0 -targets all. The legacy -reset, -clean, -version and -help flags are mapped to
+// their commands.
+func (a *Args) Evaluate(arguments []string) error {
+	if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+		a.Command = arguments[0]
+		a.CommandArgs = arguments[1:]
+		return nil
+	}
 
+	fs := a.FlagSet(goup)
+	a.projectFlags(fs)
+	a.targetFlags(fs)
+	a.dryRunFlag(fs)
+	showVersion := fs.Bool("version", false, "Shows the version")
+	showHelp := fs.Bool("help", false, "Shows this help")
+	doReset := fs.Bool("reset", false, "Performs a reset, delete the home directory and exits")
+	doClean := fs.Bool("clean", false, "Removes the project workspace, but keeps toolchains.")
+	a.CommandArgs = arguments
+	err := a.Parse(fs)
+	if err != nil {
+		return err
+	}
+
+	a.CommandArgs = nil
+	switch {
+	case *showHelp:
+		a.Command = "help"
+	case *showVersion:
+		a.Command = "version"
+	case *doReset:
+		a.Command = "reset"
+	case *doClean:
+		a.Command = "clean"
+	case fs.NArg() > 0:
+		// e.g. goup -dir . config -resolved
+		a.Command = fs.Arg(0)
+		a.CommandArgs = fs.Args()[1:]
+	default:
+		a.Command = "build"
+	}
+	return nil
+}
+
+// FlagSet creates the flags of a command with the options, which are shared by all commands. The current values
+// are the defaults.
+func (a *Args) FlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		if cmd := findCommand(name); cmd != nil {
+			fmt.Fprintf(fs.Output(), "usage: %s %s\n\n%s\n\nflags:\n", goup, cmd.synopsis, cmd.usage)
+		}
+		fs.PrintDefaults()
+	}
+	fs.StringVar((*string)(&a.BaseDir), "dir", string(a.BaseDir), "Use a custom directory to resolve relative paths from "+goup+".yml.")
+	fs.StringVar((*string)(&a.HomeDir), "home", string(a.HomeDir), "Use this as the home directory, where "+goUp+" holds toolchains, projects and workspaces.")
+	fs.IntVar((*int)(&a.LogLevel), "loglevel", int(a.LogLevel), "The LogLevel determines what is printed into the console. 0=Debug, 1=Info, 2=Warn, 3=Error")
+	return fs
+}
+
+// projectFlags registers the options of commands, which load the goup.yaml
+func (a *Args) projectFlags(fs *flag.FlagSet) {
+	fs.StringVar(&a.buildFile, "buildFile", a.buildFile, "Use a build file to load.")
+	fs.StringVar(&a.ResourcesURL, "resources", a.ResourcesURL, "XML which describes downloadable toolchains")
+	fs.DurationVar(&a.Timeout, "timeout", a.Timeout, "The deadline for the entire build, e.g. 30m. Per phase timeouts are declared in "+goup+".yaml.")
+	fs.Var(variableFlags(a.Variables), "var", "Sets a variable as KEY=VALUE, overriding the environment, env files, secrets and variables of "+goup+".yaml. Can be repeated.")
+	fs.StringVar(&a.Profile, "profile", a.Profile, "The profile of "+goup+".yaml, which overlays the build section, e.g. release.")
+}
+
+// targetFlags registers the -targets option
+func (a *Args) targetFlags(fs *flag.FlagSet) {
+	fs.Var((*targetFlags)(&a.Targets), "targets", "The targets to build, e.g. gomobile/android or gomobile/ios. Can be concated by :")
+}
+
+// dryRunFlag registers the -dry-run option
+func (a *Args) dryRunFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&a.DryRun, "dry-run", a.DryRun, "Prints what would be done, e.g. the toolchains, modules and gomobile commands of a build, without modifying anything.")
+}
+
+// strictDepsFlag registers the -strict-deps option
+func (a *Args) strictDepsFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&a.StrictDeps, "strict-deps", a.StrictDeps, "Fails, if the local modules require different versions of a dependency, instead of only reporting the conflicts.")
+}
+
+// Parse parses the CommandArgs by the given flags and resolves the dependent options. Remaining arguments
+// are available by fs.Args().
+func (a *Args) Parse(fs *flag.FlagSet) error {
+	err := fs.Parse(a.CommandArgs)
+	if err == flag.ErrHelp {
+		return err
+	}
+	if err != nil {
+		return errUsage
+	}
+
+	a.BuildFile = Path(a.buildFile).Resolve(a.BaseDir)
 	logger = &defaultLogger{a.LogLevel}
 
-	logger.Debug(Fields{"Name": goUp, "Version": version, "GOARCH": runtime.GOARCH, "GOOS": runtime.GOOS})
-	logger.Debug(Fields{"BaseDir": a.BaseDir, "BuildFile": a.BuildFile, "HomeDir": a.HomeDir, "LogLevel": a.LogLevel, "ResourcesURL": a.ResourcesURL, "Targets": a.Targets})
+	logger.Debug(Fields{"Name": goUp, "Version": version, "GOARCH": runtime.GOARCH, "GOOS": runtime.GOOS, "Command": fs.Name()})
+	logger.Debug(Fields{"BaseDir": a.BaseDir, "BuildFile": a.BuildFile, "HomeDir": a.HomeDir, "LogLevel": a.LogLevel, "ResourcesURL": a.ResourcesURL, "Targets": a.Targets, "DryRun": a.DryRun, "Timeout": a.Timeout, "Profile": a.Profile})
+	return nil
+}
 
-	if *doReset {
-		err := os.RemoveAll(a.HomeDir.String())
-		must(err)
+// noArgs fails, if the command has unexpected positional arguments
+func noArgs(fs *flag.FlagSet) error {
+	if fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected arguments: %s", fs.Name(), strings.Join(fs.Args(), " "))
 	}
+	return nil
 }
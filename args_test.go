@@ -0,0 +1,48 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		arguments   []string
+		command     string
+		commandArgs []string
+	}{
+		{nil, "build", nil},
+		{[]string{"-dir", "/tmp", "-loglevel", "0", "-targets", "all"}, "build", nil},
+		{[]string{"-reset"}, "reset", nil},
+		{[]string{"-dir", "/tmp", "-clean"}, "clean", nil},
+		{[]string{"-version"}, "version", nil},
+		{[]string{"-help"}, "help", nil},
+		{[]string{"-dir", "/tmp", "config", "-resolved"}, "config", []string{"-resolved"}},
+		{[]string{"build", "-targets", "gomobile/ios"}, "build", []string{"-targets", "gomobile/ios"}},
+	}
+	for _, test := range tests {
+		args := NewArgs()
+		if err := args.Evaluate(test.arguments); err != nil {
+			t.Fatal(test.arguments, err)
+		}
+		if args.Command != test.command || !reflect.DeepEqual(args.CommandArgs, test.commandArgs) {
+			t.Fatal(test.arguments, "unexpected command", args.Command, args.CommandArgs)
+		}
+	}
+
+	// the options of the legacy form are the defaults of the command
+	args := NewArgs()
+	if err := args.Evaluate([]string{"-dir", "/tmp/project", "-targets", "gomobile/ios", "cache", "-clear"}); err != nil {
+		t.Fatal(err)
+	}
+	flags := args.FlagSet("cache")
+	args.projectFlags(flags)
+	args.targetFlags(flags)
+	clear := flags.Bool("clear", false, "")
+	if err := args.Parse(flags); err != nil {
+		t.Fatal(err)
+	}
+	if !*clear || args.BuildFile != "/tmp/project/goup.yaml" || !reflect.DeepEqual(args.Targets, []string{"gomobile/ios"}) {
+		t.Fatal("unexpected args", args)
+	}
+}
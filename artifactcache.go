@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
 )
 
@@ -36,19 +37,17 @@ type ArtifactCache struct {
 	OutHash string
 }
 
-// calculateInHash takes all input files, like *.go and go.mod and gomobile.build. We do not want to check
-// remote changes, which may cause unstable builds, but that is a user problem, not ours.
+// calculateInHash takes the resolved build inputs and all input files, like *.go and go.mod. We do not want to
+// check remote changes, which may cause unstable builds, but that is a user problem, not ours.
+// The values of secrets are intentionally never hashed, so rotating a credential does not cause a rebuild.
 func (g *GoUp) calculateInHash() string {
 	extensions := []string{".go", "go.mod", "go.sum"}
 	hasher := sha1.New()
-	hasher.Write(sloppyBytes(ioutil.ReadFile(g.args.BuildFile.String())))
-	for _, modPath := range g.config.Build.Gomobile.Modules {
-		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
-		if !resolvedPath.Exists() {
-			// this is the external case, we will never check that for performance reasons
-			continue
-		}
-		files, err := ListFiles(resolvedPath.String())
+	for _, input := range g.buildInputs() {
+		hasher.Write([]byte(input + "\n"))
+	}
+	for _, dir := range g.inputDirs() {
+		files, err := ListFiles(dir.String())
 		if err != nil {
 			panic(err)
 		}
@@ -73,6 +72,95 @@ func (g *GoUp) calculateInHash() string {
 	return hash
 }
 
+// inputDirs returns the local modules and the directories, which replace their dependencies, either in their
+// go.mod or in the workspace. Remote modules are the external case, we will never check that for performance reasons.
+func (g *GoUp) inputDirs() []Path {
+	dirs := make([]Path, 0)
+	known := make(map[Path]bool)
+	add := func(dir Path) {
+		if !known[dir] && dir.Exists() {
+			known[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, modPath := range g.config.Build.Gomobile.Modules {
+		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
+		if !resolvedPath.Exists() {
+			continue
+		}
+		add(resolvedPath)
+		replaces, err := localReplaces(resolvedPath)
+		if err != nil {
+			logger.Debug(Fields{"msg": "cannot hash the replacements", "module": resolvedPath, "err": err.Error()})
+			continue
+		}
+		for _, r := range replaces {
+			if r.isLocal() {
+				add(Path(r.New))
+			}
+		}
+	}
+	for _, r := range g.config.Build.Gomobile.replaces {
+		if r.isLocal() {
+			add(Path(r.New))
+		}
+	}
+	return dirs
+}
+
+// buildInputs returns the resolved values, which determine the artifacts besides the sources, e.g. the gomobile
+// arguments with their interpolated variables. The values of secrets are replaced by their reference.
+func (g *GoUp) buildInputs() []string {
+	b := g.config.Build.Gomobile
+	mode := modeGopath
+	if b.moduleMode() {
+		mode = modeModules
+	}
+	inputs := []string{fmt.Sprintf("toolchain %+v", g.toolchainVersions()), "mode " + mode}
+	for _, cmd := range g.config.Before_script {
+		inputs = append(inputs, "before_script "+cmd)
+	}
+	keys := make([]string, 0, len(g.config.Variables))
+	for k := range g.config.Variables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		inputs = append(inputs, "variable "+k+"="+g.config.Variables[k])
+	}
+	for _, mod := range b.Modules {
+		inputs = append(inputs, "module "+string(mod))
+	}
+	for _, r := range b.replaces {
+		inputs = append(inputs, "replace "+r.String())
+	}
+	for _, cmd := range g.bindCommands() {
+		inputs = append(inputs, cmd.Target+" "+strings.Join(cmd.Args, " "))
+	}
+
+	// the longest value first, if a secret contains another one
+	secrets := make([]Secret, 0, len(g.config.Secrets))
+	values := make(map[string]string)
+	for _, secret := range g.config.Secrets {
+		if val, ok := g.lookupVar(secret.Name); ok && len(val) > 0 {
+			secrets = append(secrets, secret)
+			values[secret.Name] = val
+		}
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(values[secrets[i].Name]) > len(values[secrets[j].Name])
+	})
+	oldnew := make([]string, 0, 2*len(secrets))
+	for _, secret := range secrets {
+		oldnew = append(oldnew, values[secret.Name], "${"+secret.Name+"}")
+	}
+	masker := strings.NewReplacer(oldnew...)
+	for i, input := range inputs {
+		inputs[i] = masker.Replace(input)
+	}
+	return inputs
+}
+
 // calculateOutHash takes the generated out files to hash them. Absent files are hashed as 0 bytes.
 func (g *GoUp) calculateOutHash() string {
 	extensions := []string{".h", ".plist", ".modulemap"}
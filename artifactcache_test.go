@@ -0,0 +1,83 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalculateInHash(t *testing.T) {
+	newGoUp := func(version string, token string) *GoUp {
+		g := newTestGoUp(nil)
+		g.args.BaseDir = "/project"
+		g.args.Targets = []string{"all"}
+		g.secretEnv = map[string]string{"TOKEN": token}
+		g.config.Secrets = []Secret{{Name: "TOKEN", Env: "TOKEN"}}
+		g.config.Build = &Build{Gomobile: &BuildGomobile{
+			Export:  []string{"example.com/a"},
+			Android: &Android{Out: "./app.aar", Ldflags: "-X main.Version=" + version + " -X main.Token=" + token},
+		}}
+		return g
+	}
+
+	inputs := strings.Join(newGoUp("1.0", "s3cret").buildInputs(), "\n")
+	if strings.Contains(inputs, "s3cret") || !strings.Contains(inputs, "-X main.Version=1.0 -X main.Token=${TOKEN}") {
+		t.Fatalf("unexpected inputs\n%s", inputs)
+	}
+
+	hash := newGoUp("1.0", "s3cret").calculateInHash()
+	if newGoUp("1.1", "s3cret").calculateInHash() == hash {
+		t.Fatal("an interpolated value must change the hash")
+	}
+	if newGoUp("1.0", "rotated").calculateInHash() != hash {
+		t.Fatal("a rotated secret must not change the hash")
+	}
+}
+
+func TestCalculateInHashLocalReplaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"a/go.mod": "module example.com/a\n\nrequire example.com/x v1.0.0\n\nreplace example.com/x => ../x\n",
+		"a/a.go":   "package a\n",
+		"x/go.mod": "module example.com/x\n",
+		"x/x.go":   "package x\n",
+	}
+	for name, content := range files {
+		must(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.ModePerm))
+		must(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+
+	g := newTestGoUp(nil)
+	g.args.BaseDir = Path(dir)
+	g.config.Build = &Build{Gomobile: &BuildGomobile{Modules: []ModuleSpecifier{"./a"}, Export: []string{"example.com/a"}}}
+	if dirs := g.inputDirs(); !reflect.DeepEqual(dirs, []Path{Path(filepath.Join(dir, "a")), Path(filepath.Join(dir, "x"))}) {
+		t.Fatal("unexpected directories", dirs)
+	}
+
+	hash := g.calculateInHash()
+	must(ioutil.WriteFile(filepath.Join(dir, "x", "x.go"), []byte("package x\n\nconst X = 1\n"), os.ModePerm))
+	if g.calculateInHash() == hash {
+		t.Fatal("a change of a replacement must change the hash")
+	}
+
+	// the replacements of a workspace
+	g.config.Build.Gomobile.replaces = []goReplace{
+		{Old: "example.com/y", New: filepath.Join(dir, "y")},
+		{Old: "example.com/z", New: "example.com/fork/z", NewVersion: "v1.0.0"},
+	}
+	must(os.MkdirAll(filepath.Join(dir, "y"), os.ModePerm))
+	must(ioutil.WriteFile(filepath.Join(dir, "y", "go.mod"), []byte("module example.com/y\n"), os.ModePerm))
+	hash = g.calculateInHash()
+	must(ioutil.WriteFile(filepath.Join(dir, "y", "y.go"), []byte("package y\n"), os.ModePerm))
+	if g.calculateInHash() == hash {
+		t.Fatal("a change of a workspace replacement must change the hash")
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A command is an action of goup, like build or clean, which parses its own flags from Args.CommandArgs
+type command struct {
+	// name is used to invoke the command
+	name string
+	// synopsis shows the arguments, e.g. config [flags]
+	synopsis string
+	// usage describes the command in a single sentence
+	usage string
+	// run executes the command
+	run func(args *Args, w io.Writer) error
+}
+
+// commands contains all commands in the order of the help
+var commands []command
+
+func init() {
+	commands = []command{
+		{"build", "build [flags]", "Provisions the toolchains, vendors the modules and binds them. This is the default.", runBuild},
+		{"clean", "clean [flags]", "Removes the workspace of the project, but keeps the toolchains.", runClean},
+		{"reset", "reset [flags]", "Removes the home directory with all toolchains and workspaces.", runReset},
+		{"env", "env [flags]", "Prints the environment of a build, e.g. for eval \"$(goup env)\" or an IDE.", runEnv},
+		{"exec", "exec [flags] -- <command> [args...]", "Runs a command with the toolchains and variables of the project, in the current directory.", runExec},
+		{"test", "test [flags] [-- go test flags]", "Runs go test in the local modules with the toolchains and variables of the project.", runTest},
+		{"doctor", "doctor [flags]", "Checks the machine, the configuration and the toolchains and suggests remedies.", runDoctor},
+		{"toolchain", "toolchain [flags]", "Provisions the toolchains of the build file or lists the installed ones.", runToolchain},
+		{"cache", "cache [flags]", "Shows whether the artifact cache would skip the build or clears it.", runCache},
+		{"config", "config [flags]", "Prints the configuration of the build file.", PrintConfig},
+		{"schema", "schema [flags]", "Prints the JSON Schema of " + goup + ".yaml.", PrintSchema},
+		{"init", "init [flags]", "Creates a new project with a " + goup + ".yaml, a go module and the wrapper.", InitProject},
+		{"wrapper", "wrapper [flags]", "Generates the goupw wrapper script with embedded checksums.", GenerateWrapper},
+		{"version", "version", "Shows the version.", runVersion},
+		{"help", "help", "Shows this help.", runHelp},
+	}
+}
+
+// findCommand returns the command with the given name or nil
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// runBuild implements the build command
+func runBuild(args *Args, w io.Writer) error {
+	flags := args.FlagSet("build")
+	args.projectFlags(flags)
+	args.targetFlags(flags)
+	args.dryRunFlag(flags)
+	args.strictDepsFlag(flags)
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	gp, err := NewGoUp(args)
+	if err != nil {
+		return err
+	}
+	defer gp.Close()
+	if args.DryRun {
+		return gp.DryRun(w)
+	}
+	return gp.Build()
+}
+
+// runClean implements the clean command
+func runClean(args *Args, w io.Writer) error {
+	flags := args.FlagSet("clean")
+	args.projectFlags(flags)
+	args.dryRunFlag(flags)
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	// the workspace only depends on the name, so a broken configuration can still be cleaned up
+	name, err := LoadName(args.BuildFile, args.Variables)
+	if err != nil {
+		return err
+	}
+	return removeAll(args, w, "workspace", args.HomeDir.Child(name))
+}
+
+// runReset implements the reset command
+func runReset(args *Args, w io.Writer) error {
+	flags := args.FlagSet("reset")
+	args.dryRunFlag(flags)
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+	return removeAll(args, w, "home directory", args.HomeDir)
+}
+
+// removeAll removes the folder, a dry run only prints what would be removed
+func removeAll(args *Args, w io.Writer, what string, folder Path) error {
+	if args.DryRun {
+		fmt.Fprintf(w, "would remove %s %s\n", what, folder)
+		return nil
+	}
+	logger.Debug(Fields{"action": "delete", "path": folder})
+	err := os.RemoveAll(folder.String())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "removed %s %s\n", what, folder)
+	return nil
+}
+
+// runToolchain implements the toolchain command
+func runToolchain(args *Args, w io.Writer) error {
+	flags := args.FlagSet("toolchain")
+	args.projectFlags(flags)
+	list := flags.Bool("list", false, "Lists the installed toolchains instead.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	if *list {
+		dirs, err := ioutil.ReadDir(args.HomeDir.Child("toolchains").String())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, dir := range dirs {
+			if dir.IsDir() {
+				fmt.Fprintln(w, dir.Name())
+			}
+		}
+		return nil
+	}
+
+	gp, err := NewGoUp(args)
+	if err != nil {
+		return err
+	}
+	defer gp.Close()
+	err = gp.ProvisionToolchain()
+	if err != nil {
+		return err
+	}
+	resources, err := gp.toolchainResources()
+	if err != nil {
+		return err
+	}
+	for _, res := range resources {
+		fmt.Fprintf(w, "%s %s\n", res.Name, gp.toolchainFolder(res))
+	}
+	return nil
+}
+
+// runCache implements the cache command
+func runCache(args *Args, w io.Writer) error {
+	flags := args.FlagSet("cache")
+	args.projectFlags(flags)
+	args.dryRunFlag(flags)
+	clear := flags.Bool("clear", false, "Clears the artifact cache of all profiles, so that the next build is not skipped.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	g := &GoUp{args: args}
+	err = g.loadConfig()
+	if err != nil {
+		return err
+	}
+	g.buildDir = args.HomeDir.Child(g.config.Name)
+
+	if *clear {
+		files, err := filepath.Glob(g.buildDir.Child("artifacts*.json").String())
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			err := removeAll(args, w, "artifact cache", Path(file))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if g.isBuildRequired() {
+		fmt.Fprintf(w, "%s: rebuild required\n", g.artifactCacheFile())
+	} else {
+		fmt.Fprintf(w, "%s: up to date, the build would be skipped\n", g.artifactCacheFile())
+	}
+	return nil
+}
+
+// runVersion implements the version command
+func runVersion(args *Args, w io.Writer) error {
+	flags := args.FlagSet("version")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, goUp+" "+version)
+	return nil
+}
+
+// runHelp implements the help command
+func runHelp(args *Args, w io.Writer) error {
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\ncommands:\n", goup)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(w, "\nUse \"%s <command> -help\" for the flags of a command. Without a command, the flags of build and the\n", goup)
+	fmt.Fprintln(w, "legacy -reset, -clean, -version and -help flags are accepted.")
+	return nil
+}
@@ -14,15 +14,40 @@
 
 package main
 
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
 func main() {
 
-	args := &Args{}
-	args.Evaluate()
+	args := NewArgs()
+	err := args.Evaluate(os.Args[1:])
+	exitOnUsage(err)
 
-	gp, err := NewGoUp(args)
-	must(err)
+	cmd := findCommand(args.Command)
+	if cmd == nil {
+		_ = runHelp(args, os.Stderr)
+		must(fmt.Errorf("unknown command: %s", args.Command))
+	}
 
-	err = gp.Build()
+	err = cmd.run(args, os.Stdout)
+	exitOnUsage(err)
+	if code, ok := err.(exitCode); ok {
+		os.Exit(int(code))
+	}
 	must(err)
 
 }
+
+// exitOnUsage exits, if the flags could not be parsed or the help has been shown, because that has already been
+// printed by the flag package
+func exitOnUsage(err error) {
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err == errUsage {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PrintConfig implements the config command, which prints the configuration of the build file as yaml. With
+// --resolved, all includes are merged, the profile is applied and all variables are interpolated, just like
+// for a build. Secrets are masked.
+func PrintConfig(args *Args, w io.Writer) error {
+	flags := args.FlagSet("config")
+	args.projectFlags(flags)
+	resolved := flags.Bool("resolved", false, "Prints the configuration with merged includes, the applied profile and interpolated variables.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	g := &GoUp{args: args}
+	if *resolved {
+		err = g.loadConfig()
+	} else {
+		g.config = &GoUpConfiguration{}
+		err = g.config.Load(args.BuildFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	node := configYAML(reflect.ValueOf(g.config))
+	if node == nil {
+		node = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	err = enc.Encode(node)
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode configuration: %v", err)
+	}
+	_, err = io.WriteString(w, redactor.Redact(buf.String()))
+	return err
+}
+
+// configYAML converts the given configuration value into yaml nodes with the same keys as goup.yaml, in the order
+// of the struct fields. Empty values are omitted and nil is returned for them.
+func configYAML(v reflect.Value) *yaml.Node {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return configYAML(v.Elem())
+	case reflect.Struct:
+		res := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			if value := configYAML(v.Field(i)); value != nil {
+				res.Content = append(res.Content, scalarNode(yamlName(v.Type().Field(i))), value)
+			}
+		}
+		if len(res.Content) == 0 {
+			return nil
+		}
+		return res
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		res := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range keys {
+			value := configYAML(v.MapIndex(reflect.ValueOf(key).Convert(v.Type().Key())))
+			if value == nil {
+				value = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+			}
+			res.Content = append(res.Content, scalarNode(key), value)
+		}
+		if len(res.Content) == 0 {
+			return nil
+		}
+		return res
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil
+		}
+		res := &yaml.Node{Kind: yaml.SequenceNode}
+		for i := 0; i < v.Len(); i++ {
+			item := configYAML(v.Index(i))
+			if item == nil {
+				item = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+			}
+			res.Content = append(res.Content, item)
+		}
+		return res
+	default:
+		if isZero(v) {
+			return nil
+		}
+		res := &yaml.Node{}
+		must(res.Encode(v.Interface()))
+		return res
+	}
+}
+
+// scalarNode returns the node of a plain string, e.g. a key
+func scalarNode(str string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: str}
+}
@@ -0,0 +1,291 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// the states of a doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// the free disk space in the home directory, below which a check warns or fails
+const (
+	diskWarnBytes = 10 << 30
+	diskFailBytes = 2 << 30
+)
+
+// A doctorCheck is a single diagnosis of the doctor command
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// A doctorReport contains all checks and describes the machine, e.g. for a support ticket
+type doctorReport struct {
+	Version string        `json:"version"`
+	OS      string        `json:"os"`
+	Arch    string        `json:"arch"`
+	Home    Path          `json:"home"`
+	Checks  []doctorCheck `json:"checks"`
+}
+
+func (r *doctorReport) add(name string, status string, message string, hint string) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Message: message, Hint: hint})
+}
+
+// failed checks if any check has failed
+func (r *doctorReport) failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == checkFail {
+			return true
+		}
+	}
+	return false
+}
+
+// runDoctor implements the doctor command, which checks the machine, the configuration and the toolchains
+// within the environment of a build. Nothing is provisioned or modified. It fails, if any check has failed.
+func runDoctor(args *Args, w io.Writer) error {
+	flags := args.FlagSet("doctor")
+	args.projectFlags(flags)
+	args.targetFlags(flags)
+	format := flags.String("format", "text", "The output format, either text or json.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("doctor: invalid format '%s', expected text or json", *format)
+	}
+
+	// just like a dry run, the doctor must not modify anything
+	args.DryRun = true
+
+	report := &doctorReport{Version: version, OS: runtime.GOOS, Arch: runtime.GOARCH, Home: args.HomeDir}
+	checkResources(report, args)
+
+	// the configuration is loaded only once, because it registers its secrets and redactions
+	gp := &GoUp{args: args}
+	if err := gp.loadConfig(); err != nil {
+		report.add("configuration", checkFail, err.Error(), "fix "+args.BuildFile.String()+", e.g. with the help of "+goup+" schema")
+		gp = nil
+	} else {
+		report.add("configuration", checkPass, args.BuildFile.String()+" is valid", "")
+		if err := gp.setup(); err != nil {
+			report.add("resources", checkFail, err.Error(), "check your network connection or the -resources url")
+			gp = nil
+		} else {
+			defer gp.Close()
+		}
+	}
+
+	if gp != nil {
+		// the same environment manipulation, as performed by the actual build
+		gp.applyToolchainEnv()
+		checkTools(report, gp)
+		gp.checkToolchains(report)
+	} else {
+		checkTools(report, nil)
+	}
+	checkDiskSpace(report, args.HomeDir)
+
+	err = writeReport(w, *format, report)
+	if err != nil {
+		return err
+	}
+	if report.failed() {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// checkResources checks the cached resource list, which declares the downloadable toolchains
+func checkResources(r *doctorReport, args *Args) {
+	file := args.HomeDir.Child("resources.xml")
+	stat, err := os.Stat(file.String())
+	if err != nil {
+		r.add("resources", checkWarn, file.String()+" does not exist", "it is downloaded from "+args.ResourcesURL+" by the next build")
+		return
+	}
+	res := &Resources{}
+	if err := res.Load(file); err != nil {
+		r.add("resources", checkFail, fmt.Sprintf("%s is broken: %v", file, err), "remove it, to download it again")
+		return
+	}
+	if age := time.Now().Sub(stat.ModTime()); age.Hours() > 24 {
+		r.add("resources", checkWarn, fmt.Sprintf("%s is stale, last update %s ago", file, age.Round(time.Minute)), "it is refreshed by the next build")
+		return
+	}
+	r.add("resources", checkPass, file.String()+" is up to date", "")
+}
+
+// checkTools checks the required command line tools, looked up in the build environment if available
+func checkTools(r *doctorReport, g *GoUp) {
+	path := os.Getenv("PATH")
+	if g != nil {
+		path = g.env["PATH"]
+	}
+
+	ios := g == nil || g.hasIosBuild()
+	tools := []struct {
+		name     string
+		required bool
+		hint     string
+	}{
+		{"sh", true, "install a posix shell"},
+		{"git", true, "install git, it is required by go get"},
+		{"clang", ios && runtime.GOOS == "darwin", "install the xcode command line tools: xcode-select --install"},
+		{"xcrun", ios && runtime.GOOS == "darwin", "install xcode"},
+	}
+	for _, tool := range tools {
+		if !tool.required {
+			continue
+		}
+		if found, ok := lookPath(tool.name, path); ok {
+			r.add(tool.name, checkPass, found, "")
+		} else {
+			r.add(tool.name, checkFail, tool.name+" not found in PATH", tool.hint)
+		}
+	}
+
+	if g != nil && g.hasIosBuild() && runtime.GOOS != "darwin" {
+		r.add("ios", checkWarn, "the ios target can only be built on macOS", "use -targets gomobile/android or disable the ios build")
+	}
+}
+
+// lookPath finds the executable within the given PATH
+func lookPath(name string, path string) (string, bool) {
+	for _, dir := range strings.Split(path, ":") {
+		file := Path(dir).Child(name)
+		if stat, err := os.Stat(file.String()); err == nil && !stat.IsDir() && stat.Mode()&0111 != 0 {
+			return file.String(), true
+		}
+	}
+	return "", false
+}
+
+// checkToolchains checks if all toolchains are installed completely and match the declared versions
+func (g *GoUp) checkToolchains(r *doctorReport) {
+	resources, err := g.toolchainResources()
+	if err != nil {
+		r.add("toolchains", checkFail, err.Error(), "declare a toolchain version which is available for "+runtime.GOOS+"/"+runtime.GOARCH)
+		return
+	}
+
+	// a file, which must exist in a complete toolchain
+	markers := map[string]Path{
+		"go":  Path(g.env["GOROOT"]).Child("bin").Child("go"),
+		"jdk": Path(g.env["JAVA_HOME"]).Child("bin").Child("java"),
+		"ndk": Path(g.env["ANDROID_NDK_HOME"]).Child("source.properties"),
+		"sdk": Path(g.env["ANDROID_HOME"]).Child("platforms"),
+	}
+
+	for _, res := range resources {
+		folder := g.toolchainFolder(res)
+		if Path(folder.String() + ".tmp").Exists() {
+			r.add(res.Name, checkWarn, "an incomplete download exists at "+folder.String()+".tmp", "it is removed by the next build")
+		}
+		if !folder.Exists() {
+			r.add(res.Name, checkWarn, res.Name+" "+res.Version+" is not installed", "run: "+goup+" toolchain")
+			continue
+		}
+		if marker, ok := markers[res.Name]; ok && !marker.Exists() {
+			r.add(res.Name, checkFail, res.Name+" "+res.Version+" is incomplete, "+marker.String()+" does not exist", "remove "+folder.String()+" and run: "+goup+" toolchain")
+			continue
+		}
+		r.add(res.Name, checkPass, res.Name+" "+res.Version+" is installed at "+folder.String(), "")
+	}
+
+	versions := g.toolchainVersions()
+	goBinary := markers["go"]
+	if goBinary.Exists() {
+		out, err := exec.Command(goBinary.String(), "version").CombinedOutput()
+		switch {
+		case err != nil:
+			r.add("go version", checkFail, fmt.Sprintf("%s version failed: %v", goBinary, err), "remove "+g.env["GOROOT"]+" and run: "+goup+" toolchain")
+		case !strings.Contains(string(out), "go"+versions.Go+" "):
+			r.add("go version", checkFail, fmt.Sprintf("expected go%s but got: %s", versions.Go, strings.TrimSpace(string(out))), "remove "+g.env["GOROOT"]+" and run: "+goup+" toolchain")
+		default:
+			r.add("go version", checkPass, strings.TrimSpace(string(out)), "")
+		}
+	}
+
+	installed := ReadVersion(g.goPath().Child("gomobile.version").String())
+	if installed != g.config.Build.Gomobile.Toolchain.Gomobile {
+		r.add("gomobile", checkWarn, fmt.Sprintf("the workspace contains gomobile '%s' instead of '%s'", installed, g.config.Build.Gomobile.Toolchain.Gomobile), "it is rebuilt by the next build")
+	}
+
+	if err := g.validateNdk(); err != nil {
+		r.add("android target", checkFail, err.Error(), "change the api or abis of the android section")
+	}
+}
+
+// checkDiskSpace checks the free space of the home directory or its next existing parent
+func checkDiskSpace(r *doctorReport, home Path) {
+	dir := home
+	for !dir.Exists() && dir.NameCount() > 0 {
+		dir = dir.Parent()
+	}
+	free, err := diskFree(dir.String())
+	if err != nil {
+		r.add("disk space", checkWarn, fmt.Sprintf("cannot determine the free space of %s: %v", dir, err), "")
+		return
+	}
+	msg := fmt.Sprintf("%.1f GiB free in %s", float64(free)/(1<<30), dir)
+	switch {
+	case free < diskFailBytes:
+		r.add("disk space", checkFail, msg, "the toolchains require several GiB, free some space or use another -home")
+	case free < diskWarnBytes:
+		r.add("disk space", checkWarn, msg, "the toolchains require several GiB, free some space or use another -home")
+	default:
+		r.add("disk space", checkPass, msg, "")
+	}
+}
+
+// writeReport prints the report as text or json
+func writeReport(w io.Writer, format string, r *doctorReport) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	fmt.Fprintf(w, "%s %s %s/%s, home %s\n\n", goUp, r.Version, r.OS, r.Arch, r.Home)
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", strings.ToUpper(c.Status), c.Name, c.Message)
+		if len(c.Hint) > 0 {
+			fmt.Fprintf(w, "       hint: %s\n", c.Hint)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDoctorReport(t *testing.T) {
+	r := &doctorReport{Version: "1.0.0", OS: "linux", Arch: "amd64", Home: "/home/goup"}
+	r.add("git", checkPass, "/usr/bin/git", "")
+	r.add("ndk", checkWarn, "ndk r19c is not installed", "run: goup toolchain")
+	if r.failed() {
+		t.Fatal("warnings must not fail")
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeReport(buf, "text", r); err != nil {
+		t.Fatal(err)
+	}
+	expected := "[PASS] git: /usr/bin/git\n[WARN] ndk: ndk r19c is not installed\n       hint: run: goup toolchain\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatal("unexpected report", buf.String())
+	}
+
+	r.add("go version", checkFail, "expected go1.12.4", "")
+	if !r.failed() {
+		t.Fatal("expected a failed report")
+	}
+
+	buf.Reset()
+	if err := writeReport(buf, "json", r); err != nil {
+		t.Fatal(err)
+	}
+	decoded := &doctorReport{}
+	if err := json.Unmarshal(buf.Bytes(), decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Checks) != 3 || decoded.Checks[2].Status != checkFail {
+		t.Fatal("unexpected json", buf.String())
+	}
+}
+
+func TestLookPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	must(ioutil.WriteFile(Path(dir).Child("tool").String(), nil, 0755))
+	must(ioutil.WriteFile(Path(dir).Child("data").String(), nil, 0644))
+
+	if found, ok := lookPath("tool", "/nowhere:"+dir); !ok || found != Path(dir).Child("tool").String() {
+		t.Fatal("expected tool but got", found)
+	}
+	if _, ok := lookPath("data", dir); ok {
+		t.Fatal("a file which is not executable must not be found")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DryRun resolves the configuration, resources and targets and prints the plan of a build into w. Nothing is
+// downloaded, copied, vendored or compiled. Secrets are masked.
+func (g *GoUp) DryRun(dst io.Writer) error {
+	w := &bytes.Buffer{}
+	defer func() {
+		_, _ = io.WriteString(dst, redactor.Redact(w.String()))
+	}()
+
+	fmt.Fprintf(w, "project: %s\n", g.config.Name)
+	fmt.Fprintf(w, "build file: %s\n", g.args.BuildFile)
+	fmt.Fprintf(w, "workspace: %s\n", g.buildDir)
+	fmt.Fprintf(w, "targets: %s\n", strings.Join(g.args.Targets, ":"))
+	if len(g.args.Profile) > 0 {
+		fmt.Fprintf(w, "profile: %s\n", g.args.Profile)
+	}
+
+	// the cache check comes first, just like in Build
+	fmt.Fprintln(w)
+	if g.isBuildRequired() {
+		fmt.Fprintln(w, "artifact cache: rebuild required")
+	} else {
+		fmt.Fprintln(w, "artifact cache: up to date, the build would be skipped")
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "toolchains:")
+	resources, err := g.toolchainResources()
+	if err != nil {
+		return err
+	}
+	for _, res := range resources {
+		folder := g.toolchainFolder(res)
+		if folder.Exists() {
+			fmt.Fprintf(w, "  %s: installed in %s\n", res.String(), folder)
+		} else {
+			fmt.Fprintf(w, "  %s: download %s into %s\n", res.String(), res.URL, folder)
+		}
+	}
+
+	if len(g.config.Before_script) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "before_script:")
+		for _, cmd := range g.config.Before_script {
+			fmt.Fprintf(w, "  sh -c %s\n", shellQuote(cmd))
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "modules:")
+	moduleMode := g.config.Build.Gomobile.moduleMode()
+	for _, modPath := range g.config.Build.Gomobile.Modules {
+		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
+		if !resolvedPath.Exists() {
+			if moduleMode {
+				fmt.Fprintf(w, "  %s: remote, go get in the main module %s\n", modPath, g.mainModulePath())
+				continue
+			}
+			fmt.Fprintf(w, "  %s: remote, go get into %s\n", modPath, g.goPath().Child("pkg").Child("mod").Add(Path(modPath)))
+			continue
+		}
+		modName, err := getModuleName(resolvedPath.Child("go.mod"))
+		if err != nil {
+			fmt.Fprintf(w, "  %s: not a go module: %v\n", modPath, err)
+			continue
+		}
+		if moduleMode {
+			fmt.Fprintf(w, "  %s: replaced by %s in the main module %s\n", modName, resolvedPath, g.mainModulePath())
+			continue
+		}
+		targetDir := g.goPath().Child("src").Add(Path(modName))
+		fmt.Fprintf(w, "  %s: copy %s to %s, upgrade to the versions selected for all modules and go mod vendor\n", modName, resolvedPath, targetDir)
+	}
+	for _, r := range g.config.Build.Gomobile.replaces {
+		fmt.Fprintf(w, "  workspace replace %s\n", r)
+	}
+	if g.args.StrictDeps {
+		fmt.Fprintln(w, "  fail on conflicting dependency versions")
+	}
+
+	// the same environment manipulation, as performed by the actual build
+	g.applyToolchainEnv()
+	g.applyModeEnv()
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "environment:")
+	keys := make([]string, 0)
+	for k, v := range g.env {
+		if os.Getenv(k) != v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := g.env[k]
+		if g.isProtectedEnvKey(k) {
+			v = "<HIDDEN>"
+		}
+		fmt.Fprintf(w, "  %s=%s\n", k, v)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	if err := g.validateNdk(); err != nil {
+		fmt.Fprintf(w, "  problem: %v\n", err)
+	}
+	for _, cmd := range g.bindCommands() {
+		fmt.Fprintf(w, "  %s: cd %s && %s", cmd.Target, g.bindPath(), g.goPath().Child("bin").Child("gomobile"))
+		for _, arg := range cmd.Args {
+			fmt.Fprintf(w, " %s", shellQuote(arg))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// shellQuote returns the string as is, if it is safe to paste into a shell, otherwise it is single quoted
+func shellQuote(str string) string {
+	if len(str) > 0 && strings.IndexFunc(str, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=@,+", r))
+	}) < 0 {
+		return str
+	}
+	return "'" + strings.Replace(str, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("the resources only provide amd64 toolchains")
+	}
+	dir, err := ioutil.TempDir("", "goup-dryrun")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a dry run never downloads, but reads the cached resources regardless of their age
+	home := filepath.Join(dir, "home")
+	resources, err := ioutil.ReadFile("resources.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(home, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(home, "resources.xml"), resources, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	project := filepath.Join(dir, "project")
+	if err := os.MkdirAll(filepath.Join(project, "lib"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(project, "lib", "go.mod"), []byte("module example.com/lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `name: app
+before_script:
+  - echo it's up
+build:
+  gomobile:
+    toolchain:
+      go: 1.17.8
+      ndk: r19c
+      sdk: 4333796
+      jdk: 8u212b03
+      gomobile: wdy-v0.0.2
+    android:
+      out: app.aar
+      javapkg: com.example
+    modules:
+      - ./lib
+    export:
+      - example.com/lib
+`
+	if err := ioutil.WriteFile(filepath.Join(project, "goup.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := NewArgs()
+	args.BaseDir = Path(project)
+	args.HomeDir = Path(home)
+	args.BuildFile = Path(filepath.Join(project, "goup.yaml"))
+	args.Targets = []string{"gomobile/android"}
+	args.DryRun = true
+	gp, err := NewGoUp(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gp.Close()
+
+	buf := &bytes.Buffer{}
+	if err := gp.DryRun(buf); err != nil {
+		t.Fatal(err)
+	}
+	plan := buf.String()
+	expected := []string{
+		"project: app\n",
+		"build file: " + filepath.Join(project, "goup.yaml") + "\n",
+		"workspace: " + filepath.Join(home, "app") + "\n",
+		"targets: gomobile/android\n",
+		"artifact cache: rebuild required\n",
+		"  go@1.17.8[" + runtime.GOOS + "|amd64]: download https://dl.google.com/go/go1.17.8.",
+		"  sh -c 'echo it'\\''s up'\n",
+		"  example.com/lib: copy " + filepath.Join(project, "lib") + " to " + filepath.Join(home, "app", "go", "src", "example.com", "lib") + ",",
+		"  GO111MODULE=off\n",
+		"  GOPATH=" + filepath.Join(home, "app", "go") + "\n",
+		"  gomobile/android: cd " + filepath.Join(home, "app", "go") + " && " + filepath.Join(home, "app", "go", "bin", "gomobile") +
+			" bind -v -o " + filepath.Join(project, "app.aar") + " -javapkg com.example -target=android example.com/lib\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(plan, str) {
+			t.Fatalf("expected %q in the plan\n%s", str, plan)
+		}
+	}
+
+	// nothing has been downloaded or created
+	files, err := ioutil.ReadDir(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected only the resources in %s, but got %d files", home, len(files))
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		str      string
+		expected string
+	}{
+		{"", "''"},
+		{"-ldflags", "-ldflags"},
+		{"/a/b.aar", "/a/b.aar"},
+		{"-X=main.version=1.0", "-X=main.version=1.0"},
+		{"a b", "'a b'"},
+		{"it's", `'it'\''s'`},
+		{"$HOME", "'$HOME'"},
+	}
+	for _, test := range tests {
+		if actual := shellQuote(test.str); actual != test.expected {
+			t.Fatalf("%q: expected %s but got %s", test.str, test.expected, actual)
+		}
+	}
+}
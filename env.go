@@ -0,0 +1,142 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// envFormats are the supported output formats of the env command
+var envFormats = []string{"sh", "fish", "powershell", "json"}
+
+// runEnv implements the env command, which prints the environment of a build, i.e. the toolchain paths and the
+// variables, so that it can be applied to a shell, e.g. by eval "$(goup env)", or imported by an IDE. The
+// toolchains are not provisioned, use the toolchain command for that.
+func runEnv(args *Args, w io.Writer) error {
+	flags := args.FlagSet("env")
+	args.projectFlags(flags)
+	format := flags.String("format", "sh", "The output format, one of "+strings.Join(envFormats, ", ")+".")
+	secrets := flags.String("secrets", "masked", "Either masked or plain. Masked replaces secrets and protected variables by "+redacted+".")
+	all := flags.Bool("all", false, "Prints the entire environment, not only the variables which differ from the current one.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+	if *secrets != "masked" && *secrets != "plain" {
+		return fmt.Errorf("env: invalid -secrets value '%s', expected masked or plain", *secrets)
+	}
+
+	gp, err := NewGoUp(args)
+	if err != nil {
+		return err
+	}
+	defer gp.Close()
+
+	// the same environment manipulation, as performed by the actual build
+	gp.applyToolchainEnv()
+	gp.applyModeEnv()
+
+	env := make(map[string]string)
+	masked := make(map[string]bool)
+	for k, v := range gp.env {
+		if current, ok := os.LookupEnv(k); ok && current == v && !*all {
+			continue
+		}
+		if *secrets == "masked" {
+			if gp.isProtectedEnvKey(k) {
+				v = redacted
+			} else {
+				v = redactor.Redact(v)
+			}
+			masked[k] = v != gp.env[k]
+		}
+		env[k] = v
+	}
+
+	for _, res := range gp.mustToolchainResources() {
+		if !gp.toolchainFolder(res).Exists() {
+			fmt.Fprintf(os.Stderr, "warning: %s is not installed, run: %s toolchain\n", res.String(), goup)
+		}
+	}
+
+	return writeEnv(w, *format, env, masked)
+}
+
+// mustToolchainResources returns the toolchain resources or nil, if they cannot be resolved
+func (g *GoUp) mustToolchainResources() []Resource {
+	res, err := g.toolchainResources()
+	if err != nil {
+		logger.Warn(Fields{"msg": "cannot resolve toolchains", "err": err.Error()})
+		return nil
+	}
+	return res
+}
+
+// writeEnv prints the variables in the given format, sorted by key. Masked variables are commented out in the
+// shell formats, so that applying the output never replaces a value by its mask.
+func writeEnv(w io.Writer, format string, env map[string]string, masked map[string]bool) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(env)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := env[k]
+		if masked[k] {
+			fmt.Fprint(w, "# ")
+		}
+		switch format {
+		case "sh":
+			fmt.Fprintf(w, "export %s=%s\n", k, shellQuote(v))
+		case "fish":
+			// fish treats path variables as lists
+			if strings.HasSuffix(k, "PATH") {
+				elems := make([]string, 0)
+				for _, elem := range strings.Split(v, ":") {
+					elems = append(elems, fishQuote(elem))
+				}
+				fmt.Fprintf(w, "set -gx %s %s\n", k, strings.Join(elems, " "))
+			} else {
+				fmt.Fprintf(w, "set -gx %s %s\n", k, fishQuote(v))
+			}
+		case "powershell":
+			fmt.Fprintf(w, "$env:%s = '%s'\n", k, strings.Replace(v, "'", "''", -1))
+		default:
+			return fmt.Errorf("env: unknown format '%s', expected one of %s", format, strings.Join(envFormats, ", "))
+		}
+	}
+	return nil
+}
+
+// fishQuote quotes a string for the fish shell, which only knows \\ and \' within single quotes
+func fishQuote(str string) string {
+	return "'" + strings.Replace(strings.Replace(str, `\`, `\\`, -1), "'", `\'`, -1) + "'"
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteEnv(t *testing.T) {
+	env := map[string]string{"PATH": "/a b:/c", "NAME": "it's", "GITLAB_TOKEN": redacted}
+	masked := map[string]bool{"GITLAB_TOKEN": true}
+
+	expected := map[string]string{
+		"sh":         "# export GITLAB_TOKEN='<HIDDEN>'\nexport NAME='it'\\''s'\nexport PATH='/a b:/c'\n",
+		"fish":       "# set -gx GITLAB_TOKEN '<HIDDEN>'\nset -gx NAME 'it\\'s'\nset -gx PATH '/a b' '/c'\n",
+		"powershell": "# $env:GITLAB_TOKEN = '<HIDDEN>'\n$env:NAME = 'it''s'\n$env:PATH = '/a b:/c'\n",
+		"json":       "{\n  \"GITLAB_TOKEN\": \"<HIDDEN>\",\n  \"NAME\": \"it's\",\n  \"PATH\": \"/a b:/c\"\n}\n",
+	}
+	for format, text := range expected {
+		buf := &bytes.Buffer{}
+		if err := writeEnv(buf, format, env, masked); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != text {
+			t.Fatalf("%s: expected\n%s\nbut got\n%s", format, text, buf.String())
+		}
+	}
+
+	if err := writeEnv(&bytes.Buffer{}, "cmd", env, masked); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
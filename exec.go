@@ -0,0 +1,101 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// exitCode is returned by a command, to exit with the given code without logging an error
+type exitCode int
+
+func (e exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+// runExec implements the exec command, which runs an arbitrary command within the toolchain environment of the
+// project, e.g. goup exec -- go test ./... The toolchains are provisioned like for a build and are protected by
+// shared locks while the command runs. The exit code of the command is passed through.
+func runExec(args *Args, w io.Writer) error {
+	flags := args.FlagSet("exec")
+	args.projectFlags(flags)
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("exec: missing command, e.g. %s exec -- go version", goup)
+	}
+
+	gp, err := NewGoUp(args)
+	if err != nil {
+		return err
+	}
+	defer gp.Close()
+
+	gp.enterPhase(phaseBeforeScript)
+	gp.beforeScript()
+
+	err = gp.ProvisionToolchain()
+	if err != nil {
+		return err
+	}
+
+	// nobody must modify the toolchains or the workspace, while the command uses them
+	toolchainLock, err := gp.rlock(args.HomeDir.Child("toolchain.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to acquire toolchain lock: %v", err)
+	}
+	defer toolchainLock.Unlock()
+
+	projectLock, err := gp.rlock(gp.buildDir.Child("project.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to acquire project lock: %v", err)
+	}
+	defer projectLock.Unlock()
+
+	return gp.exec(w, flags.Arg(0), flags.Args()[1:]...)
+}
+
+// exec runs the command in the current directory. The standard output is written into w, the other streams are
+// connected to those of this process.
+func (g *GoUp) exec(w io.Writer, name string, args ...string) error {
+	// the command is looked up in the toolchain path, just like for run
+	err := os.Setenv("PATH", g.env["PATH"])
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(g.ctx, name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	for k, v := range g.env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	logger.Debug(Fields{"exec": name, "args": args})
+
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && g.ctx.Err() == nil {
+		return exitCode(exitErr.ExitCode())
+	}
+	if err != nil && g.ctx.Err() != nil {
+		return fmt.Errorf("%s: timeout: %v", name, g.ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunExecMissingCommand(t *testing.T) {
+	args := NewArgs()
+	args.CommandArgs = []string{"-buildFile", "does-not-exist.yaml"}
+	err := runExec(args, &bytes.Buffer{})
+	if err == nil || !strings.HasPrefix(err.Error(), "exec: missing command") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestExecExitCode(t *testing.T) {
+	g := newTestGoUp(nil)
+	buf := &bytes.Buffer{}
+	err := g.exec(buf, "sh", "-c", "echo hello; exit 3")
+	if code, ok := err.(exitCode); !ok || code != 3 {
+		t.Fatalf("expected exit code 3 but got %v", err)
+	}
+	if buf.String() != "hello\n" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+
+	if err := g.exec(buf, "true"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,6 +4,5 @@ go 1.12
 
 require (
 	github.com/gofrs/flock v0.7.1
-	gopkg.in/yaml.v2 v2.2.2
+	gopkg.in/yaml.v3 v3.0.1
 )
- 
\ No newline at end of file
@@ -0,0 +1,368 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runTest implements the test command, which runs go test ./... in each local module with the toolchains and
+// variables of the project. The results are printed like go test does and optionally written as JUnit XML.
+// Remaining arguments are passed to go test, e.g. goup test -- -race -run TestLogin
+func runTest(args *Args, w io.Writer) error {
+	flags := args.FlagSet("test")
+	args.projectFlags(flags)
+	args.dryRunFlag(flags)
+	args.strictDepsFlag(flags)
+	junit := flags.String("junit", "", "Writes the results as JUnit XML into this file, relative to -dir.")
+	workspace := flags.Bool("workspace", false, "Also tests the exported packages within the workspace of gomobile, after preparing it like a build does.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	testFlags := flags.Args()
+
+	gp, err := NewGoUp(args)
+	if err != nil {
+		return err
+	}
+	defer gp.Close()
+
+	modules := gp.localModules()
+	if args.DryRun {
+		for _, dir := range modules {
+			fmt.Fprintf(w, "cd %s && go test -json %s\n", dir, strings.Join(append(testFlags, "./..."), " "))
+		}
+		if *workspace {
+			exports := strings.Join(append(testFlags, gp.config.Build.Gomobile.Export...), " ")
+			gp.applyModeEnv()
+			fmt.Fprintf(w, "cd %s && GO111MODULE=%s go test -json %s\n", gp.bindPath(), gp.env["GO111MODULE"], exports)
+		}
+		return nil
+	}
+
+	gp.enterPhase(phaseBeforeScript)
+	gp.beforeScript()
+
+	err = gp.ProvisionToolchain()
+	if err != nil {
+		return err
+	}
+
+	// the toolchains must not change while testing. The workspace is only modified by -workspace.
+	toolchainLock, err := gp.rlock(args.HomeDir.Child("toolchain.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to acquire toolchain lock: %v", err)
+	}
+	defer toolchainLock.Unlock()
+
+	lockProject := gp.rlock
+	if *workspace {
+		lockProject = gp.lock
+	}
+	projectLock, err := lockProject(gp.buildDir.Child("project.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to acquire project lock: %v", err)
+	}
+	defer projectLock.Unlock()
+
+	report := newTestReport(w)
+	for _, dir := range modules {
+		gp.chdir(dir)
+		gp.setEnv("GO111MODULE", "on")
+		err := gp.goTest(report, dir.String(), append(testFlags, "./...")...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *workspace {
+		gp.enterPhase(phaseVendor)
+		err := gp.prepareBindWorkspace()
+		if err != nil {
+			return err
+		}
+
+		gp.chdir(gp.bindPath())
+		gp.applyModeEnv()
+		report.scope = "workspace/"
+		err = gp.goTest(report, "workspace", append(testFlags, gp.config.Build.Gomobile.Export...)...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(*junit) > 0 {
+		err := report.writeJUnit(Path(*junit).Resolve(args.BaseDir))
+		if err != nil {
+			return err
+		}
+	}
+
+	if report.failed {
+		return exitCode(1)
+	}
+	return nil
+}
+
+// localModules returns the folders of the declared modules, which exist locally. Remote modules are only tested
+// within the workspace.
+func (g *GoUp) localModules() []Path {
+	dirs := make([]Path, 0)
+	for _, modPath := range g.config.Build.Gomobile.Modules {
+		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
+		if !resolvedPath.Exists() {
+			logger.Info(Fields{"msg": "remote module is not tested", "module": modPath})
+			continue
+		}
+		dirs = append(dirs, resolvedPath)
+	}
+	return dirs
+}
+
+// goTest invokes go test -json in the current directory and feeds the events into the report. Failed tests are
+// recorded, only a failure to run go test at all is returned.
+func (g *GoUp) goTest(report *testReport, name string, args ...string) error {
+	g.enterPhase(phaseTest)
+	ctx, cancel := g.commandContext()
+	defer cancel()
+
+	cmd := g.command(ctx, "go", append([]string{"test", "-json"}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to run go test: %v", err)
+	}
+
+	failures := report.failures
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		report.consume(scanner.Text())
+	}
+	err = cmd.Wait()
+
+	report.print(stderr.String())
+	if err != nil && ctx.Err() != nil {
+		return g.timeoutError("go test "+strings.Join(args, " "), strings.Split(stderr.String(), "\n"))
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		// e.g. a build failure, which is only reported on stderr by older go versions
+		if report.failures == failures {
+			report.addFailure(name, "[build failed]", stderr.String())
+		}
+		report.failed = true
+		return nil
+	}
+	return err
+}
+
+// A testEvent is a line of go test -json, see go doc test2json
+type testEvent struct {
+	Action      string
+	Package     string
+	Test        string
+	Elapsed     float64
+	Output      string
+	ImportPath  string
+	FailedBuild string
+}
+
+// A testReport collects the events of go test -json into JUnit test suites, one per package, and prints them
+// like go test does without -v
+type testReport struct {
+	w      io.Writer
+	suites []*junitTestSuite
+	// output contains the output of each running test and of each package build
+	output map[string]*strings.Builder
+	// failures counts the failed test cases
+	failures int
+	// failed is set, if any test or go test itself has failed
+	failed bool
+	// scope prefixes the names of the test suites, to distinguish the runs in the workspace
+	scope string
+}
+
+// newTestReport creates an empty report, which prints into w
+func newTestReport(w io.Writer) *testReport {
+	return &testReport{w: w, output: make(map[string]*strings.Builder)}
+}
+
+// print writes the redacted text into the console
+func (r *testReport) print(text string) {
+	_, _ = io.WriteString(r.w, redactor.Redact(text))
+}
+
+// suite returns the test suite of the package, it is created if required
+func (r *testReport) suite(pkg string) *junitTestSuite {
+	pkg = r.scope + pkg
+	for _, s := range r.suites {
+		if s.Name == pkg {
+			return s
+		}
+	}
+	s := &junitTestSuite{Name: pkg, Time: "0.000"}
+	r.suites = append(r.suites, s)
+	return s
+}
+
+// buffer returns the collected output of the key, it is created if required
+func (r *testReport) buffer(key string) *strings.Builder {
+	sb, ok := r.output[key]
+	if !ok {
+		sb = &strings.Builder{}
+		r.output[key] = sb
+	}
+	return sb
+}
+
+// consume processes a line of go test -json. Other lines are printed as is.
+func (r *testReport) consume(line string) {
+	event := testEvent{}
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+		r.print(line + "\n")
+		return
+	}
+
+	switch {
+	case event.Action == "build-output":
+		r.print(event.Output)
+		r.buffer("build " + event.ImportPath).WriteString(event.Output)
+	case event.Action == "output" && len(event.Test) == 0:
+		r.print(event.Output)
+		r.suite(event.Package).SystemOut += event.Output
+	case event.Action == "output":
+		r.buffer(event.Package + " " + event.Test).WriteString(event.Output)
+	case event.Action == "pass" || event.Action == "fail" || event.Action == "skip":
+		if len(event.Test) > 0 {
+			r.finishTest(event)
+		} else {
+			r.finishPackage(event)
+		}
+	}
+}
+
+// finishTest records the result of a test
+func (r *testReport) finishTest(event testEvent) {
+	suite := r.suite(event.Package)
+	output := r.buffer(event.Package + " " + event.Test).String()
+	tc := &junitTestCase{Classname: event.Package, Name: event.Test, Time: fmt.Sprintf("%.3f", event.Elapsed)}
+	switch event.Action {
+	case "fail":
+		r.print(output)
+		tc.Failure = &junitMessage{Message: "Failed", Text: redactor.Redact(output)}
+		suite.Failures++
+		r.failures++
+		r.failed = true
+	case "skip":
+		tc.Skipped = &junitMessage{Message: redactor.Redact(strings.TrimSpace(output))}
+		suite.Skipped++
+	}
+	suite.Tests++
+	suite.Testcases = append(suite.Testcases, tc)
+}
+
+// finishPackage records the elapsed time of a package and a failure, which is not caused by a test, e.g.
+// a build error or a panic in TestMain
+func (r *testReport) finishPackage(event testEvent) {
+	suite := r.suite(event.Package)
+	suite.Time = fmt.Sprintf("%.3f", event.Elapsed)
+	if event.Action != "fail" || suite.Failures > 0 {
+		return
+	}
+	if len(event.FailedBuild) > 0 {
+		r.addFailure(event.Package, "[build failed]", r.buffer("build "+event.FailedBuild).String())
+	} else {
+		r.addFailure(event.Package, "[setup failed]", suite.SystemOut)
+	}
+}
+
+// addFailure records a failed pseudo test case, for failures outside of tests
+func (r *testReport) addFailure(pkg string, name string, output string) {
+	suite := r.suite(pkg)
+	suite.Tests++
+	suite.Failures++
+	suite.Testcases = append(suite.Testcases, &junitTestCase{Classname: pkg, Name: name, Time: "0.000",
+		Failure: &junitMessage{Message: "Failed", Text: redactor.Redact(output)}})
+	r.failures++
+	r.failed = true
+}
+
+// writeJUnit writes the test suites as JUnit XML, as understood by e.g. GitLab and Jenkins
+func (r *testReport) writeJUnit(file Path) error {
+	for _, s := range r.suites {
+		s.SystemOut = redactor.Redact(s.SystemOut)
+	}
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: r.suites}, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(file.Parent().String(), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create folder for %s: %v", file, err)
+	}
+	err = ioutil.WriteFile(file.String(), append([]byte(xml.Header), append(data, '\n')...), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write JUnit report: %v", err)
+	}
+	logger.Debug(Fields{"junit": file})
+	return nil
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name          `xml:"testsuites"`
+	Suites  []*junitTestSuite `xml:"testsuite"`
+}
+
+// A junitTestSuite contains the test cases of a package
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Testcases []*junitTestCase `xml:"testcase"`
+	SystemOut string           `xml:"system-out,omitempty"`
+}
+
+// A junitTestCase is the result of a single test
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+// A junitMessage describes a failure or why a test has been skipped
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
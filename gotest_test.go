@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// a shortened go test -json output of a package with a passing, a failing and a skipped test and of a
+// package which does not compile
+const testJSONOutput = `{"Action":"start","Package":"example.com/app"}
+{"Action":"run","Package":"example.com/app","Test":"TestPass"}
+{"Action":"output","Package":"example.com/app","Test":"TestPass","Output":"=== RUN   TestPass\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestPass","Output":"--- PASS: TestPass (0.00s)\n"}
+{"Action":"pass","Package":"example.com/app","Test":"TestPass","Elapsed":0.001}
+{"Action":"run","Package":"example.com/app","Test":"TestFail"}
+{"Action":"output","Package":"example.com/app","Test":"TestFail","Output":"=== RUN   TestFail\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestFail","Output":"    app_test.go:7: boom\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestFail","Output":"--- FAIL: TestFail (0.25s)\n"}
+{"Action":"fail","Package":"example.com/app","Test":"TestFail","Elapsed":0.25}
+{"Action":"run","Package":"example.com/app","Test":"TestSkip"}
+{"Action":"output","Package":"example.com/app","Test":"TestSkip","Output":"    app_test.go:9: not today\n"}
+{"Action":"skip","Package":"example.com/app","Test":"TestSkip","Elapsed":0}
+{"Action":"output","Package":"example.com/app","Output":"FAIL\n"}
+{"Action":"output","Package":"example.com/app","Output":"FAIL\texample.com/app\t0.252s\n"}
+{"Action":"fail","Package":"example.com/app","Elapsed":0.252}
+{"ImportPath":"example.com/app/broken [example.com/app/broken.test]","Action":"build-output","Output":"# example.com/app/broken\n"}
+{"ImportPath":"example.com/app/broken [example.com/app/broken.test]","Action":"build-output","Output":"./broken.go:2:17: undefined: x\n"}
+{"ImportPath":"example.com/app/broken [example.com/app/broken.test]","Action":"build-fail"}
+{"Action":"output","Package":"example.com/app/broken","Output":"FAIL\texample.com/app/broken [build failed]\n"}
+{"Action":"fail","Package":"example.com/app/broken","Elapsed":0,"FailedBuild":"example.com/app/broken [example.com/app/broken.test]"}
+?   	example.com/app/tools	[no test files]`
+
+func TestTestReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+	report := newTestReport(buf)
+	for _, line := range strings.Split(testJSONOutput, "\n") {
+		report.consume(line)
+	}
+
+	if !report.failed || report.failures != 2 {
+		t.Fatalf("expected 2 failures but got %d", report.failures)
+	}
+	expected := "    app_test.go:7: boom\n--- FAIL: TestFail (0.25s)\nFAIL\nFAIL\texample.com/app\t0.252s\n" +
+		"# example.com/app/broken\n./broken.go:2:17: undefined: x\nFAIL\texample.com/app/broken [build failed]\n" +
+		"?   \texample.com/app/tools\t[no test files]\n"
+	if !strings.HasSuffix(buf.String(), expected) {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := Path(dir).Child("reports").Child("junit.xml")
+	if err := report.writeJUnit(file); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(file.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	suites := junitTestSuites{}
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatal(err)
+	}
+	if len(suites.Suites) != 2 {
+		t.Fatalf("expected 2 suites but got %d", len(suites.Suites))
+	}
+
+	app := suites.Suites[0]
+	if app.Name != "example.com/app" || app.Tests != 3 || app.Failures != 1 || app.Skipped != 1 || app.Time != "0.252" {
+		t.Fatalf("unexpected suite %+v", app)
+	}
+	if tc := app.Testcases[1]; tc.Name != "TestFail" || tc.Time != "0.250" || !strings.Contains(tc.Failure.Text, "boom") {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+	if tc := app.Testcases[2]; tc.Skipped == nil || !strings.Contains(tc.Skipped.Message, "not today") {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+
+	broken := suites.Suites[1]
+	if tc := broken.Testcases[0]; tc.Name != "[build failed]" || !strings.Contains(tc.Failure.Text, "undefined: x") {
+		t.Fatalf("unexpected test case %+v", tc)
+	}
+}
@@ -15,13 +15,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -51,95 +54,238 @@ type GoUp struct {
 
 	// artifactCache contains information about the last build and is used to avoid unnecessary builds
 	artifactCache *ArtifactCache
+
+	// ctx carries the deadline of the entire build
+	ctx context.Context
+
+	// cancel releases the deadline of ctx
+	cancel context.CancelFunc
+
+	// phase is the current build phase, which determines the timeout of each executed command
+	phase string
+
+	// secretEnv contains the variables from env files and secrets, which are applied into env
+	secretEnv map[string]string
 }
 
+// the build phases, which can be limited by timeouts
+const (
+	phaseToolchain    = "toolchain"
+	phaseVendor       = "vendor"
+	phaseBind         = "bind"
+	phaseBeforeScript = "before_script"
+	phaseTest         = "test"
+)
+
 // NewGoUp creates a new GoUp builder
 func NewGoUp(args *Args) (*GoUp, error) {
 	gp := &GoUp{}
 	gp.args = args
-	gp.config = &GoUpConfiguration{}
-	err := gp.config.Load(gp.args.BuildFile)
+	err := gp.loadConfig()
 	if err != nil {
 		return nil, err
 	}
+	err = gp.setup()
+	if err != nil {
+		return nil, err
+	}
+	return gp, nil
+}
 
-	logger.Debug(Fields{"buildFile": gp.config.String()})
+// setup prepares the workspace, the resources and the environment of an already loaded configuration
+func (g *GoUp) setup() error {
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	if g.args.Timeout > 0 {
+		g.ctx, g.cancel = context.WithTimeout(context.Background(), g.args.Timeout)
+	}
 
-	gp.buildDir = gp.args.HomeDir.Child(gp.config.Name)
-	logger.Debug(Fields{"buildDir": gp.buildDir})
+	g.buildDir = g.args.HomeDir.Child(g.config.Name)
+	logger.Debug(Fields{"buildDir": g.buildDir})
 
-	if gp.args.ClearWorkspace {
-		logger.Debug(Fields{"action": "delete", "path": gp.buildDir})
-		err := os.RemoveAll(gp.buildDir.String())
-		if err != nil {
-			return nil, err
-		}
-		os.Exit(0)
+	if !g.args.DryRun {
+		must(os.MkdirAll(g.args.BaseDir.String(), os.ModePerm))
+		must(os.MkdirAll(g.args.HomeDir.String(), os.ModePerm))
+		must(os.MkdirAll(g.buildDir.String(), os.ModePerm))
 	}
 
-	must(os.MkdirAll(gp.args.BaseDir.String(), os.ModePerm))
-	must(os.MkdirAll(gp.args.HomeDir.String(), os.ModePerm))
-	must(os.MkdirAll(gp.buildDir.String(), os.ModePerm))
-
-	res, err := gp.loadResources()
+	res, err := g.loadResources()
 	if err != nil {
-		return nil, err
+		g.Close()
+		return err
 	}
-	gp.resources = res
-	logger.Debug(Fields{"resources": gp.resources})
+	g.resources = res
+	logger.Debug(Fields{"resources": g.resources})
 
-	gp.env = make(map[string]string)
-	// insert all custom defined env variables
-	for k, v := range gp.config.Variables {
-		gp.setEnv(k, v)
+	g.env = make(map[string]string)
+	// the inherited environment has the lowest precedence
+	for _, e := range os.Environ() {
+		pair := strings.SplitN(e, "=", 2)
+		if len(pair) != 2 || len(pair[0]) == 0 {
+			continue
+		}
+		g.setEnv(pair[0], pair[1])
 	}
 
-	// insert on top of that all env variables from us
-	for _, e := range os.Environ() {
-		pair := strings.Split(e, "=")
-		gp.setEnv(pair[0], pair[1])
+	// the custom defined env variables override the inherited ones
+	for k, v := range g.config.Variables {
+		g.setEnv(k, v)
 	}
 
-	return gp, nil
+	// variables from env files and secrets are never logged, because the redactor knows them
+	for k, v := range g.secretEnv {
+		g.setEnv(k, v)
+	}
+
+	// the -var flags always win
+	for k, v := range g.args.Variables {
+		g.setEnv(k, v)
+	}
+	return nil
+}
+
+// Close releases the build deadline. Commands must not be executed afterwards.
+func (g *GoUp) Close() {
+	g.cancel()
+}
+
+// lookupVar resolves a variable for the interpolation of goup.yaml. The -var flags win over env files and
+// secrets, which win over the declared variables, which win over the process environment.
+func (g *GoUp) lookupVar(name string) (string, bool) {
+	if val, ok := g.args.Variables[name]; ok {
+		return val, true
+	}
+	if val, ok := g.secretEnv[name]; ok {
+		return val, true
+	}
+	if val, ok := g.config.Variables[name]; ok {
+		return val, true
+	}
+	return os.LookupEnv(name)
 }
 
 // setEnv set a key/value environment variable
 func (g *GoUp) setEnv(key string, val string) {
 	g.env[key] = val
+	if g.isProtectedEnvKey(key) {
+		redactor.AddSecret(val)
+	}
 	logger.Debug(Fields{"$export": "env", key: val})
 }
 
+// loadConfig loads the build file with all includes, applies the profile, interpolates all variables and validates
+// the result
+func (g *GoUp) loadConfig() error {
+	g.config = &GoUpConfiguration{}
+	err := g.config.Load(g.args.BuildFile)
+	if err != nil {
+		return err
+	}
+
+	// an older version would misinterpret newer keys, so this is checked before anything else
+	err = g.config.checkGoUpVersion()
+	if err != nil {
+		return err
+	}
+
+	err = g.config.ResolveIncludes(func(url string) ([]byte, error) {
+		return g.cachedDownload(url, g.args.HomeDir.Child("includes").Child(Sha256(url)+".yaml"))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = g.config.ApplyProfile(g.args.Profile)
+	if err != nil {
+		return err
+	}
+
+	// variables can only refer to the -var flags and the process environment
+	err = interpolateValue(reflect.ValueOf(&g.config.Variables), "variables", func(name string) (string, bool) {
+		if val, ok := g.args.Variables[name]; ok {
+			return val, true
+		}
+		return os.LookupEnv(name)
+	}, true)
+	if err != nil {
+		return g.config.locate(err)
+	}
+
+	// secrets must be known, before anything gets logged
+	err = g.loadSecrets()
+	if err != nil {
+		return err
+	}
+
+	// everything else can also refer to variables, env files and secrets
+	variables := g.config.Variables
+	g.config.Variables = nil
+	err = interpolate(g.config, g.lookupVar)
+	g.config.Variables = variables
+	if err != nil {
+		return g.config.locate(err)
+	}
+
+	err = g.config.loadWorkspace(g.args.BaseDir)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug(Fields{"buildFile": g.config.String()})
+
+	return g.config.Validate()
+}
+
 // loadResources only updates once a day or if the ~/.goup/resources.xml is missing
 func (g *GoUp) loadResources() (*Resources, error) {
-	file := g.args.HomeDir.Child("resources.xml")
-	stat, err := os.Stat(file.String())
-	if err != nil || time.Now().Sub(stat.ModTime()).Hours() > 24 {
-		logger.Debug(Fields{"action": "downloading", "url": g.args.ResourcesURL})
-		_ = os.Remove(file.String())
-		res, err := http.Get(g.args.ResourcesURL)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get resource list: %v", err)
-		}
-		defer res.Body.Close()
-		data, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to download resource list: %v", err)
-		}
-		err = ioutil.WriteFile(file.String(), data, os.ModePerm)
-		if err != nil {
-			return nil, fmt.Errorf("failed to save resource list: %v", err)
-		}
-		logger.Debug(Fields{"action": "updated", "file": file})
+	data, err := g.cachedDownload(g.args.ResourcesURL, g.args.HomeDir.Child("resources.xml"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get resource list: %v", err)
 	}
 	res := &Resources{}
-	logger.Debug(Fields{"action": "parsing", "file": file})
-	err = res.Load(file)
+	err = res.Parse(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load resources: %v", err)
 	}
 	return res, nil
 }
 
+// cachedDownload returns the content of url, which is cached in file for 24 hours. A dry run only reads the cache.
+func (g *GoUp) cachedDownload(url string, file Path) ([]byte, error) {
+	stat, err := os.Stat(file.String())
+	if err == nil && (g.args.DryRun || time.Now().Sub(stat.ModTime()).Hours() <= 24) {
+		logger.Debug(Fields{"action": "parsing", "file": file})
+		return ioutil.ReadFile(file.String())
+	}
+
+	logger.Debug(Fields{"action": "downloading", "url": url})
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, res.Status)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	if g.args.DryRun {
+		return data, nil
+	}
+
+	err = os.MkdirAll(file.Parent().String(), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	err = ioutil.WriteFile(file.String(), data, os.ModePerm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save %s: %v", file, err)
+	}
+	logger.Debug(Fields{"action": "updated", "file": file})
+	return data, nil
+}
+
 // prepareAndroidSDK is required because the SDK is still not yet functional after downloading.
 // Also it saves it things always in the wrong top level folder. Another wtf is that the sdkmanager
 // only works with Java 8, even though we have Java 11 today.
@@ -165,73 +311,103 @@ func (g *GoUp) prepareAndroidSDK() error {
 	return nil
 }
 
-// prepareGomobileToolchain downloads go, ndk and sdk
-func (g *GoUp) prepareGomobileToolchain() error {
+// toolchainVersions contains the resolved versions of all tools which make up a gomobile toolchain
+type toolchainVersions struct {
+	Go       string
+	Gomobile string
+	Ndk      string
+	Sdk      string
+	Jdk      string
+}
+
+// defaultToolchainVersions are used for each undeclared toolchain version
+var defaultToolchainVersions = toolchainVersions{Go: "1.12.4", Gomobile: "wdy-v0.0.1", Ndk: "r19c", Sdk: "4333796", Jdk: "8u212b03"}
+
+// toolchainVersions returns the configured toolchain versions, using our defaults for missing declarations
+func (g *GoUp) toolchainVersions() toolchainVersions {
+	tc := g.config.Build.Gomobile.Toolchain
+	v := toolchainVersions{Go: tc.Go, Gomobile: tc.Gomobile, Ndk: tc.Ndk, Sdk: tc.Sdk, Jdk: tc.Jdk}
+	if IsEmpty(v.Go) {
+		v.Go = defaultToolchainVersions.Go
+	}
+	if IsEmpty(v.Gomobile) {
+		v.Gomobile = defaultToolchainVersions.Gomobile
+	}
+	if IsEmpty(v.Ndk) {
+		v.Ndk = defaultToolchainVersions.Ndk
+	}
+	if IsEmpty(v.Sdk) {
+		v.Sdk = defaultToolchainVersions.Sdk
+	}
+	if IsEmpty(v.Jdk) {
+		v.Jdk = defaultToolchainVersions.Jdk
+	}
+	return v
+}
+
+// toolchainResources resolves the downloadable resources for go, gomobile, ndk, sdk and jdk
+func (g *GoUp) toolchainResources() ([]Resource, error) {
 	resources := make([]Resource, 0)
+	versions := g.toolchainVersions()
 
 	// go
-	goVersion := g.config.Build.Gomobile.Toolchain.Go
-	if IsEmpty(goVersion) {
-		goVersion = "1.12.4"
-	}
-	res, err := g.resources.Get("go", goVersion)
+	res, err := g.resources.Get("go", versions.Go)
 	if err != nil {
-		return fmt.Errorf("cannot prepare android build: %v", err)
+		return nil, fmt.Errorf("cannot prepare android build: %v", err)
 	}
 	resources = append(resources, res)
 
 	// gomobile
-	goMobileVersion := g.config.Build.Gomobile.Toolchain.Gomobile
-	if IsEmpty(goMobileVersion) {
-		goMobileVersion = "wdy-v0.0.1"
-	}
-	res, err = g.resources.Get("gomobile", goMobileVersion)
+	res, err = g.resources.Get("gomobile", versions.Gomobile)
 	if err != nil {
-		return fmt.Errorf("cannot prepare android build (gomobile version): %v", err)
+		return nil, fmt.Errorf("cannot prepare android build (gomobile version): %v", err)
 	}
 	resources = append(resources, res)
 
 	// android ndk
-	ndkVersion := g.config.Build.Gomobile.Toolchain.Ndk
-	if IsEmpty(ndkVersion) {
-		ndkVersion = "r19c"
-	}
-	res, err = g.resources.Get("ndk", ndkVersion)
+	res, err = g.resources.Get("ndk", versions.Ndk)
 	if err != nil {
-		return fmt.Errorf("cannot prepare android build: %v", err)
+		return nil, fmt.Errorf("cannot prepare android build: %v", err)
 	}
 	//	if g.hasAndroidBuild() { //TODO seems to be required to also build ios?
 	resources = append(resources, res)
 	//	}
 
 	// android sdk
-	sdkVersion := g.config.Build.Gomobile.Toolchain.Sdk
-	if IsEmpty(sdkVersion) {
-		sdkVersion = "433796"
-	}
-	res, err = g.resources.Get("sdk", sdkVersion)
+	res, err = g.resources.Get("sdk", versions.Sdk)
 	if err != nil {
-		return fmt.Errorf("cannot prepare android sdk: %v", err)
+		return nil, fmt.Errorf("cannot prepare android sdk: %v", err)
 	}
 	//if g.hasAndroidBuild() { //TODO seems to be required to also build ios?
 	resources = append(resources, res)
 	//}
 
 	// java jdk
-	jdkVersion := g.config.Build.Gomobile.Toolchain.Jdk
-	if IsEmpty(jdkVersion) {
-		sdkVersion = "8u212b03"
-	}
-	res, err = g.resources.Get("jdk", jdkVersion)
+	res, err = g.resources.Get("jdk", versions.Jdk)
 	if err != nil {
-		return fmt.Errorf("cannot prepare jdk: %v", err)
+		return nil, fmt.Errorf("cannot prepare jdk: %v", err)
 	}
 	//	if g.hasAndroidBuild() { //TODO seems to be required to also build ios?
 	resources = append(resources, res)
 	//	}
 
+	return resources, nil
+}
+
+// toolchainFolder returns the folder into which the given resource is installed
+func (g *GoUp) toolchainFolder(res Resource) Path {
+	return g.toolchainPath().Child(res.Name + "-" + res.Version)
+}
+
+// prepareGomobileToolchain downloads go, ndk and sdk
+func (g *GoUp) prepareGomobileToolchain() error {
+	resources, err := g.toolchainResources()
+	if err != nil {
+		return err
+	}
+
 	for _, res := range resources {
-		targetFolder := g.args.HomeDir.Child("toolchains").Child(res.Name + "-" + res.Version)
+		targetFolder := g.toolchainFolder(res)
 		if targetFolder.Exists() {
 			logger.Debug(Fields{"toolchain": res.String(), "status": "exists"})
 			continue
@@ -241,7 +417,9 @@ func (g *GoUp) prepareGomobileToolchain() error {
 		_ = os.RemoveAll(tmpTargetFolder.String())
 		must(os.MkdirAll(tmpTargetFolder.String(), os.ModePerm))
 
-		err := downloadAndUnpack(res.URL, tmpTargetFolder)
+		ctx, cancel := g.commandContext()
+		err := downloadAndUnpack(ctx, res.URL, tmpTargetFolder)
+		cancel()
 		if err != nil {
 			return fmt.Errorf("failed to provide resource: %s: %v", res.String(), err)
 		}
@@ -285,13 +463,31 @@ func (g *GoUp) prepareGomobileToolchain() error {
 
 	}
 
-	goRoot := g.args.HomeDir.Child("toolchains").Child("go-" + goVersion)
-	javaHome := g.args.HomeDir.Child("toolchains").Child("jdk-" + jdkVersion)
+	g.applyToolchainEnv()
+
+	err = os.MkdirAll(g.goPath().String(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	_, _ = g.run("which", "go")
+	_, _ = g.run("type", "-p", "go")
+	_, _ = g.run("go", "version")
+	_, _ = g.run("java", "-version")
+	return nil
+}
+
+// applyToolchainEnv sets GOROOT, GOPATH, PATH and the java and android variables to point into the
+// installed toolchains. It only touches the environment, so it is also safe to use without an installation.
+func (g *GoUp) applyToolchainEnv() {
+	versions := g.toolchainVersions()
+	goRoot := g.toolchainPath().Child("go-" + versions.Go)
+	javaHome := g.toolchainPath().Child("jdk-" + versions.Jdk)
 	if runtime.GOOS == "darwin" {
 		javaHome = javaHome.Child("Contents").Child("Home")
 	}
 
-	sdkHome := g.args.HomeDir.Child("toolchains").Child("sdk-" + sdkVersion)
+	sdkHome := g.toolchainPath().Child("sdk-" + versions.Sdk)
 
 	g.setEnv("GOROOT", goRoot.String())
 	g.setEnv("GOPATH", g.goPath().String())
@@ -304,32 +500,22 @@ func (g *GoUp) prepareGomobileToolchain() error {
 			sdkHome.Child("bin").String()+":"+
 			g.env["PATH"])
 
-	err = os.MkdirAll(g.goPath().String(), os.ModePerm)
-	if err != nil {
-		return err
-	}
-
-	_, _ = g.run("which", "go")
-	_, _ = g.run("type", "-p", "go")
-	_, _ = g.run("go", "version")
-
-	g.setEnv("ANDROID_NDK_HOME", g.args.HomeDir.Child("toolchains").Child("ndk-"+ndkVersion).String())
+	g.setEnv("ANDROID_NDK_HOME", g.toolchainPath().Child("ndk-"+versions.Ndk).String())
 	g.setEnv("NDK_PATH", g.env["ANDROID_NDK_HOME"])
-	g.setEnv("ANDROID_HOME", g.args.HomeDir.Child("toolchains").Child("sdk-"+sdkVersion).String())
+	g.setEnv("ANDROID_HOME", sdkHome.String())
 	g.setEnv("ANDROID_SDK_ROOT", g.env["ANDROID_HOME"])
 
 	g.setEnv("JAVA_HOME", javaHome.String())
-
-	_, _ = g.run("java", "-version")
-	return nil
 }
 
 func (g *GoUp) cleanGoPath() {
 
-	dedub := make(map[string]string)
+	dedub := make(map[string]bool)
 	home, _ := os.UserHomeDir()
 	homeGo := filepath.Join(home, "go", "bin")
 
+	// keep the order, the first occurrence wins just like for a lookup
+	cleanPaths := make([]string, 0)
 	tmpPath := g.env["PATH"]
 	for _, path := range strings.Split(tmpPath, ":") {
 		switch path {
@@ -340,13 +526,12 @@ func (g *GoUp) cleanGoPath() {
 		case homeGo + "/":
 			continue
 		default:
-			dedub[path] = path
+			if !dedub[path] {
+				dedub[path] = true
+				cleanPaths = append(cleanPaths, path)
+			}
 		}
 	}
-	cleanPaths := make([]string, 0)
-	for k := range dedub {
-		cleanPaths = append(cleanPaths, k)
-	}
 
 	g.env["PATH"] = strings.Join(cleanPaths, ":")
 }
@@ -368,6 +553,39 @@ func (g *GoUp) chdir(path Path) {
 	logger.Debug(Fields{"cd": path})
 }
 
+// enterPhase sets the current build phase, whose timeout applies to all subsequently executed commands
+func (g *GoUp) enterPhase(phase string) {
+	g.phase = phase
+	logger.Debug(Fields{"phase": phase})
+}
+
+// commandContext returns a context limited by the build deadline and the timeout of the current phase
+func (g *GoUp) commandContext() (context.Context, context.CancelFunc) {
+	// the timeouts have already been validated by NewGoUp
+	timeout, _ := g.config.Timeouts.Get(g.phase)
+	if timeout > 0 {
+		return context.WithTimeout(g.ctx, timeout)
+	}
+	return context.WithCancel(g.ctx)
+}
+
+// timeoutError describes which command has been killed and why, including the last lines of its output
+func (g *GoUp) timeoutError(cmd string, lines []string) error {
+	reason := fmt.Sprintf("the build deadline of %s has been exceeded", g.args.Timeout)
+	if g.ctx.Err() == nil {
+		timeout, _ := g.config.Timeouts.Get(g.phase)
+		reason = fmt.Sprintf("the %s timeout of %s has been exceeded", g.phase, timeout)
+	}
+
+	tail := make([]string, 0)
+	for i := len(lines) - 1; i >= 0 && len(tail) < 10; i-- {
+		if !IsEmpty(lines[i]) {
+			tail = append([]string{lines[i]}, tail...)
+		}
+	}
+	return fmt.Errorf("command killed, because %s: %s\nlast output:\n%s", reason, cmd, strings.Join(tail, "\n"))
+}
+
 // chmodX invokes chmod +x
 func (g *GoUp) chmodX(path Path) error {
 	_, err := g.run("chmod", "+x", path.String())
@@ -389,7 +607,8 @@ func (g *GoUp) isProtectedEnvKey(key string) bool {
 	return false
 }
 
-func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error) {
+// command prepares the external program with the environment and the working directory of GoUp
+func (g *GoUp) command(ctx context.Context, name string, args ...string) *exec.Cmd {
 	// we need to assemble the path before execution
 	// because exec.Command uses LookPath before the environment has been set for execution
 	err := os.Setenv("PATH", g.env["PATH"])
@@ -397,7 +616,8 @@ func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error
 		panic(err)
 	}
 
-	cmd := exec.Command(name, args...)
+	cmd := exec.CommandContext(ctx, name, args...)
+	setWaitDelay(cmd)
 
 	fields := Fields{}
 	for k, v := range g.env {
@@ -409,14 +629,17 @@ func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error
 		}
 	}
 	logger.Debug(fields)
-
-	tmpCmd := name + " "
-	for _, a := range args {
-		tmpCmd += a + " "
-	}
-	logger.Debug(Fields{"exec": tmpCmd})
+	logger.Debug(Fields{"exec": name + " " + strings.Join(args, " ")})
 
 	cmd.Dir = g.cwd.String()
+	return cmd
+}
+
+func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error) {
+	ctx, cancel := g.commandContext()
+	defer cancel()
+
+	cmd := g.command(ctx, name, args...)
 	if len(pipeTo) != 0 {
 		pipe, err := cmd.StdinPipe()
 		if err != nil {
@@ -429,6 +652,9 @@ func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error
 
 	lines := strings.Split(string(stdoutStderr), "\n")
 	for _, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
 		if err != nil {
 			logger.Error(Fields{"": line})
 		} else {
@@ -436,6 +662,10 @@ func (g *GoUp) run2(name string, pipeTo []byte, args ...string) ([]string, error
 		}
 	}
 
+	if err != nil && ctx.Err() != nil {
+		return lines, g.timeoutError(name+" "+strings.Join(args, " "), lines)
+	}
+
 	return lines, err
 }
 
@@ -538,18 +768,26 @@ func (g *GoUp) prepareGomobile() error {
 }
 
 // copyModulesToWorkspace performs the heavy lifting to get gomobile happy with "modules".
-// It evaluates all module dependencies, collects them and copies the maximum resolved (by go mod vendor)
-// version into the workspace
+// It evaluates all module dependencies, selects their versions for all modules together by minimal version
+// selection, vendors them (by go mod vendor) and copies them into the workspace
 func (g *GoUp) copyModulesToWorkspace() error {
 	dependencies := make(map[string]VendoredModule)
 	g.chdir(g.goPath())
 	g.setEnv("GO111MODULE", "on")
 	resolvedLocalModulePaths := make([]Path, 0)
+	localModules := make(map[string]bool)
+	graph := make(moduleGraph)
+	requested := make(map[string]map[string]Version)
+	targetDirs := make([]Path, 0)
+	localNames := make([]string, 0)
+	localDirs := make([]goReplace, 0)
+	moduleReplaces := make([]goReplace, 0)
 	for _, modPath := range g.config.Build.Gomobile.Modules {
 		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
 
 		//non-existing paths are treated as remote sources, they are downloaded directly
-		if !resolvedPath.Exists() {
+		remote := !resolvedPath.Exists()
+		if remote {
 			// not a local mode, try to go get
 			_, err := g.run("go", "get", string(modPath))
 			if err != nil {
@@ -565,6 +803,7 @@ func (g *GoUp) copyModulesToWorkspace() error {
 			return fmt.Errorf("expected '%s' to have a go.mod file. This is not a go module: %v", resolvedPath, err)
 		}
 		logger.Debug(Fields{"name": modName})
+		localModules[modName] = true
 
 		// copy declared go modules into go path
 		targetDir := g.goPath().Child("src").Add(Path(modName))
@@ -580,8 +819,70 @@ func (g *GoUp) copyModulesToWorkspace() error {
 			return fmt.Errorf("failed to copy directory %s: %v", targetDir, err)
 		}
 
-		// vendor module dependencies for each module
+		// relative replacements would point elsewhere from the copy
+		replaces, err := localReplaces(resolvedPath)
+		if err != nil {
+			return fmt.Errorf("failed to read replace directives: %v", err)
+		}
+		moduleReplaces = append(moduleReplaces, replaces...)
+		if !remote {
+			localDirs = append(localDirs, goReplace{Old: modName, New: resolvedPath.String()})
+		}
+		targetDirs = append(targetDirs, targetDir)
+		localNames = append(localNames, modName)
+	}
+
+	// each copy gets the same replacements, merged like in module mode, otherwise the copies may vendor different
+	// contents for the same module version
+	replaces := mergeReplaces(localDirs, g.config.Build.Gomobile.replaces, moduleReplaces)
+	for i, targetDir := range targetDirs {
+		modName := localNames[i]
+		g.chdir(targetDir)
+		for _, r := range replaces {
+			if r.Old == modName {
+				continue
+			}
+			_, err := g.run("go", "mod", "edit", "-replace="+r.editArg())
+			if err != nil {
+				return fmt.Errorf("failed to apply replace %s: %v", r, err)
+			}
+		}
+
+		// the requirements of all local modules are resolved together, like go would do for a single build
+		lines, err := g.run("go", "mod", "graph")
+		if err != nil {
+			return fmt.Errorf("failed to read module graph: %v", err)
+		}
+		modGraph, err := parseModuleGraph(lines)
+		if err != nil {
+			return fmt.Errorf("failed to parse module graph of %s: %v", modName, err)
+		}
+		graph.add(modGraph)
+		requested[modName] = modGraph.buildList(moduleVersion{Path: modName, Main: true})
+	}
+
+	selected := graph.buildList(graph.mainModules()...)
+	err := g.reportConflicts(dependencyConflicts(selected, requested))
+	if err != nil {
+		return err
+	}
+
+	for i, targetDir := range targetDirs {
 		g.chdir(targetDir)
+
+		// upgrade each dependency to the selected version, so that all copies vendor the same versions
+		for _, path := range sortedModulePaths(requested[localNames[i]]) {
+			v := requested[localNames[i]][path]
+			if sel, ok := selected[path]; ok && !localModules[path] && sel.IsNewer(v) {
+				logger.Debug(Fields{"action": "upgrade", "module": path, "from": v.String(), "to": sel.String()})
+				_, err = g.run("go", "get", "-d", path+"@"+sel.String())
+				if err != nil {
+					return fmt.Errorf("failed to upgrade %s to %s: %v", path, sel, err)
+				}
+			}
+		}
+
+		// vendor module dependencies for each module
 		_, err = g.run("go", "mod", "vendor")
 		if err != nil {
 			return fmt.Errorf("failed to vendor module dependencies: %v", err)
@@ -592,26 +893,30 @@ func (g *GoUp) copyModulesToWorkspace() error {
 			return fmt.Errorf("failed to parse vendor module information: %v", err)
 		}
 
-		// collected and inspect all modules: upgrade to the largest declared version, causing potential semver conflict
+		// collected and inspect all modules: the copies have been upgraded to the same versions, but keep the highest anyway
 		for _, mod := range modules {
-			logger.Debug(Fields{"action": "found", "module": mod.ModuleName, "version": mod.Version.String()})
+			logger.Debug(Fields{"action": "found", "module": mod.ModuleName, "version": mod.Version.String(), "replacement": mod.Replacement})
 			dep, ok := dependencies[mod.ModuleName]
 
 			if !ok || mod.Version.IsNewer(dep.Version) {
 				dependencies[mod.ModuleName] = mod
-				dep = mod
-
-				logger.Debug(Fields{"action": "upgrade", "module": mod.ModuleName, "version": mod.Version.String()})
 			}
 		}
 	}
 
 	sortedDependencies := asSortedSlice(dependencies)
 
-	// a cleaning run, to purge only once the dependency-roots
+	// local modules may depend on each other, e.g. within a workspace, but their copies are already in place
+	for i := len(sortedDependencies) - 1; i >= 0; i-- {
+		if localModules[sortedDependencies[i].ModuleName] {
+			sortedDependencies = append(sortedDependencies[:i], sortedDependencies[i+1:]...)
+		}
+	}
+
+	// a cleaning run, to purge only once the dependencies. Their parents may contain the local modules.
 	for _, dep := range sortedDependencies {
 		targetDir := g.goPath().Child("src").Add(Path(dep.ModuleName))
-		err := os.RemoveAll(targetDir.Parent().String())
+		err := os.RemoveAll(targetDir.String())
 		if err != nil {
 			return fmt.Errorf("failed to remove module target directory: %v", err)
 		}
@@ -629,6 +934,11 @@ func (g *GoUp) copyModulesToWorkspace() error {
 			logger.Debug(Fields{"action": "move", "msg": "already absent, has been moved?", "from": dep.Local})
 			continue
 		}
+		// a nested module may already have been moved together with its parent module
+		err = os.RemoveAll(targetDir.String())
+		if err != nil {
+			return fmt.Errorf("failed to remove module target directory: %v", err)
+		}
 		err = os.Rename(dep.Local.String(), targetDir.String())
 		if err != nil {
 			return fmt.Errorf("failed to move: %s->%s: %v", dep.Local, targetDir, err)
@@ -665,36 +975,60 @@ func (g *GoUp) hasTarget(target string) bool {
 
 // hasAndroidBuild returns true if a gomobile android section is defined and enabled
 func (g *GoUp) hasAndroidBuild() bool {
-	return g.config.Build.Gomobile != nil && g.config.Build.Gomobile.Android != nil && g.hasTarget("gomobile/android")
+	return g.config.Build.Gomobile != nil && g.config.Build.Gomobile.Android != nil &&
+		!g.config.Build.Gomobile.Android.Disabled && g.hasTarget("gomobile/android")
 }
 
 // hasIosBuild returns true if a gomobile ios section is defined and enabled
 func (g *GoUp) hasIosBuild() bool {
-	return g.config.Build.Gomobile != nil && g.config.Build.Gomobile.Ios != nil && g.hasTarget("gomobile/ios")
+	return g.config.Build.Gomobile != nil && g.config.Build.Gomobile.Ios != nil &&
+		!g.config.Build.Gomobile.Ios.Disabled && g.hasTarget("gomobile/ios")
 }
 
-func (g *GoUp) compileGomobile() error {
-	logger.Debug(Fields{"action": "compiling gomobile"})
-	g.chdir(g.goPath())
-	g.setEnv("GO111MODULE", "off")
+// commonBindArgs returns the gomobile flags which are available for all targets
+func commonBindArgs(ldflags string, tags []string, trimpath bool, extraArgs []string) []string {
+	args := make([]string, 0)
+	if !IsEmpty(ldflags) {
+		args = append(args, "-ldflags", ldflags)
+	}
+	if len(tags) > 0 {
+		args = append(args, "-tags", strings.Join(tags, " "))
+	}
+	if trimpath {
+		args = append(args, "-trimpath")
+	}
+	return append(args, extraArgs...)
+}
+
+// A bindCommand is a single gomobile bind invocation for one target
+type bindCommand struct {
+	// Target is e.g. gomobile/android or gomobile/ios
+	Target string
+	// Args are the arguments passed to gomobile
+	Args []string
+}
 
+// bindCommands assembles the gomobile bind invocations for all enabled targets
+func (g *GoUp) bindCommands() []bindCommand {
+	commands := make([]bindCommand, 0)
 	if g.hasAndroidBuild() {
 		args := []string{"bind", "-v"}
 
 		outFile := g.config.Build.Gomobile.Android.Out.Resolve(g.args.BaseDir)
 		args = append(args, "-o", outFile.String())
 
-		if len(g.config.Build.Gomobile.Android.Javapkg) > 0 {
-			args = append(args, "-javapkg", g.config.Build.Gomobile.Android.Javapkg)
+		android := g.config.Build.Gomobile.Android
+		if len(android.Javapkg) > 0 {
+			args = append(args, "-javapkg", android.Javapkg)
 		}
-		args = append(args, "-target=android")
-
-		args = append(args, g.config.Build.Gomobile.Export...)
-		_, err := g.run("bin/gomobile", args...)
-		if err != nil {
-			return err
+		args = append(args, "-target="+android.androidTarget())
+		if android.Api > 0 {
+			args = append(args, "-androidapi", strconv.Itoa(android.Api))
 		}
+		args = append(args, commonBindArgs(android.Ldflags, android.Tags, android.Trimpath, android.ExtraArgs)...)
 
+		args = append(args, g.config.Build.Gomobile.Export...)
+		commands = append(commands, bindCommand{Target: "gomobile/android", Args: args})
 	}
 
 	if g.hasIosBuild() {
@@ -706,13 +1040,34 @@ func (g *GoUp) compileGomobile() error {
 		outFile := g.config.Build.Gomobile.Ios.Out.Resolve(g.args.BaseDir)
 		args = append(args, "-o", outFile.String())
 
-		if len(g.config.Build.Gomobile.Ios.Prefix) > 0 {
-			args = append(args, "-prefix", g.config.Build.Gomobile.Ios.Prefix)
+		ios := g.config.Build.Gomobile.Ios
+		if len(ios.Prefix) > 0 {
+			args = append(args, "-prefix", ios.Prefix)
+		}
+		if len(ios.Bundleid) > 0 {
+			args = append(args, "-bundleid", ios.Bundleid)
 		}
 		args = append(args, "-target=ios")
+		args = append(args, commonBindArgs(ios.Ldflags, ios.Tags, ios.Trimpath, ios.ExtraArgs)...)
 
 		args = append(args, g.config.Build.Gomobile.Export...)
-		_, err := g.run("bin/gomobile", args...)
+		commands = append(commands, bindCommand{Target: "gomobile/ios", Args: args})
+	}
+	return commands
+}
+
+func (g *GoUp) compileGomobile() error {
+	logger.Debug(Fields{"action": "compiling gomobile"})
+	err := g.validateNdk()
+	if err != nil {
+		return err
+	}
+
+	g.chdir(g.bindPath())
+	g.applyModeEnv()
+
+	for _, cmd := range g.bindCommands() {
+		_, err := g.run(g.goPath().Child("bin").Child("gomobile").String(), cmd.Args...)
 		if err != nil {
 			return err
 		}
@@ -724,7 +1079,7 @@ func (g *GoUp) compileGomobile() error {
 // avoid that in any case (e.g. 30s for "hello world" on a beefy machine) which takes a fraction of a second
 // for go itself.
 func (g *GoUp) isBuildRequired() bool {
-	cacheFile := g.buildDir.Child("artifacts.json")
+	cacheFile := g.artifactCacheFile()
 	g.artifactCache = &ArtifactCache{}
 	err := g.artifactCache.Load(cacheFile.String())
 	if err != nil {
@@ -743,6 +1098,15 @@ func (g *GoUp) isBuildRequired() bool {
 	return false
 }
 
+// artifactCacheFile returns the file of the artifact cache, which is distinct for each profile, so that switching
+// between e.g. debug and release does not invalidate the cache of the other
+func (g *GoUp) artifactCacheFile() Path {
+	if len(g.args.Profile) > 0 {
+		return g.buildDir.Child("artifacts-" + g.args.Profile + ".json")
+	}
+	return g.buildDir.Child("artifacts.json")
+}
+
 // updateBuildCache calculates and writes the current in/out hashes
 func (g *GoUp) updateBuildCache() {
 	inHash := g.calculateInHash()
@@ -750,7 +1114,7 @@ func (g *GoUp) updateBuildCache() {
 
 	g.artifactCache.InHash = inHash
 	g.artifactCache.OutHash = outHash
-	cacheFile := g.buildDir.Child("artifacts.json")
+	cacheFile := g.artifactCacheFile()
 	err := g.artifactCache.Save(cacheFile.String())
 	if err != nil {
 		fmt.Println(err)
@@ -768,6 +1132,37 @@ func (g *GoUp) beforeScript() {
 	}
 }
 
+// ProvisionToolchain installs the toolchains of the build file, if required, and applies their environment
+func (g *GoUp) ProvisionToolchain() error {
+	// the toolchains can only be modified by one process at once
+	fileLock, err := g.lock(g.args.HomeDir.Child("toolchain.lock"))
+	if err != nil {
+		return fmt.Errorf("failed to acquire toolchain lock: %v", err)
+	}
+
+	g.enterPhase(phaseToolchain)
+	err = g.prepareGomobileToolchain()
+	if err != nil {
+		return fmt.Errorf("failed to prepare gomobile build: %v", err)
+	}
+
+	err = g.prepareGomobileFrozen()
+	if err != nil {
+		return err
+	}
+
+	err = g.prepareAndroidSDK()
+	if err != nil {
+		return fmt.Errorf("failed to init android sdk: %v", err)
+	}
+
+	err = fileLock.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to unlock toolchains: %v", err)
+	}
+	return nil
+}
+
 // Build performs the actual build process
 func (g *GoUp) Build() error {
 	started := time.Now()
@@ -778,50 +1173,28 @@ func (g *GoUp) Build() error {
 		return nil
 	}
 
+	g.enterPhase(phaseBeforeScript)
 	g.beforeScript()
 
-	// the toolchains can only be modified by one process at once
-	fileLock := flock.New(g.args.HomeDir.Child("toolchain.lock").String())
-	err := fileLock.Lock()
+	err := g.ProvisionToolchain()
 	if err != nil {
-		return fmt.Errorf("failed to acquire toolchain lock: %v", err)
-	}
-
-	{
-		err = g.prepareGomobileToolchain()
-		if err != nil {
-			return fmt.Errorf("failed to prepare gomobile build: %v", err)
-		}
-
-		err = g.prepareGomobileFrozen()
-		if err != nil {
-			return err
-		}
-
-		err = g.prepareAndroidSDK()
-		if err != nil {
-			return fmt.Errorf("failed to init android sdk: %v", err)
-		}
-	}
-
-	err = fileLock.Unlock()
-	if err != nil {
-		return fmt.Errorf("failed to unlock toolchains: %v", err)
+		return err
 	}
 
 	// only one project is allowed to be compiled at time
-	fileLock = flock.New(g.buildDir.Child("project.lock").String())
-	err = fileLock.Lock()
+	fileLock, err := g.lock(g.buildDir.Child("project.lock"))
 	if err != nil {
 		return fmt.Errorf("failed to acquire project lock: %v", err)
 	}
 
 	{
-		err = g.copyModulesToWorkspace()
+		g.enterPhase(phaseVendor)
+		err = g.prepareBindWorkspace()
 		if err != nil {
 			return err
 		}
 
+		g.enterPhase(phaseBind)
 		err = g.compileGomobile()
 		if err != nil {
 			return err
@@ -837,3 +1210,35 @@ func (g *GoUp) Build() error {
 
 	return nil
 }
+
+// lock acquires the denoted interprocess file lock, waiting at most until the build deadline
+func (g *GoUp) lock(file Path) (*flock.Flock, error) {
+	fileLock := flock.New(file.String())
+	err := g.waitLock(file, fileLock.TryLockContext)
+	if err != nil {
+		return nil, err
+	}
+	return fileLock, nil
+}
+
+// rlock acquires a shared lock, which excludes only the exclusive lock of the file
+func (g *GoUp) rlock(file Path) (*flock.Flock, error) {
+	fileLock := flock.New(file.String())
+	err := g.waitLock(file, fileLock.TryRLockContext)
+	if err != nil {
+		return nil, err
+	}
+	return fileLock, nil
+}
+
+// waitLock retries to acquire the lock, until the build context is done
+func (g *GoUp) waitLock(file Path, tryLock func(ctx context.Context, retryDelay time.Duration) (bool, error)) error {
+	locked, err := tryLock(g.ctx, time.Second)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return fmt.Errorf("timed out waiting for %s", file)
+	}
+	return nil
+}
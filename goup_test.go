@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestGoUp returns a GoUp which executes commands in the current directory with the given timeouts
+func newTestGoUp(timeouts *Timeouts) *GoUp {
+	return &GoUp{
+		args:   &Args{},
+		config: &GoUpConfiguration{Timeouts: timeouts},
+		env:    map[string]string{"PATH": os.Getenv("PATH")},
+		cwd:    Path("."),
+		ctx:    context.Background(),
+	}
+}
+
+func TestTimeoutErrorTail(t *testing.T) {
+	g := newTestGoUp(&Timeouts{Vendor: "1m"})
+	g.enterPhase(phaseVendor)
+	lines := []string{"first"}
+	for i := 1; i <= 12; i++ {
+		lines = append(lines, "line "+strconv.Itoa(i), "")
+	}
+	err := g.timeoutError("go mod vendor", lines)
+
+	expected := "command killed, because the vendor timeout of 1m0s has been exceeded: go mod vendor\nlast output:\n" +
+		"line 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\nline 11\nline 12"
+	if err.Error() != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, err.Error())
+	}
+}
+
+func TestTimeoutErrorDeadline(t *testing.T) {
+	g := newTestGoUp(nil)
+	g.args.Timeout = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	g.ctx = ctx
+
+	err := g.timeoutError("go build", nil)
+	if !strings.HasPrefix(err.Error(), "command killed, because the build deadline of 1ms has been exceeded: go build") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestCommandContextKills(t *testing.T) {
+	g := newTestGoUp(&Timeouts{Bind: "100ms"})
+	g.enterPhase(phaseBind)
+	started := time.Now()
+	_, err := g.run("sleep", "10")
+	if err == nil || !strings.HasPrefix(err.Error(), "command killed, because the bind timeout of 100ms has been exceeded: sleep 10") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if time.Since(started) > 5*time.Second {
+		t.Fatalf("sleep has not been killed")
+	}
+
+	// other phases are not limited
+	g.enterPhase(phaseVendor)
+	if _, err := g.run("sleep", "0.2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCommonBindArgs(t *testing.T) {
+	cases := []struct {
+		ldflags   string
+		tags      []string
+		trimpath  bool
+		extraArgs []string
+		expected  []string
+	}{
+		{"", nil, false, nil, []string{}},
+		{"-s -w", nil, false, nil, []string{"-ldflags", "-s -w"}},
+		{"", []string{"a", "b"}, false, nil, []string{"-tags", "a b"}},
+		{"", nil, true, nil, []string{"-trimpath"}},
+		{"", nil, false, []string{"-x", "-work"}, []string{"-x", "-work"}},
+		{"-X main.V=1", []string{"release"}, true, []string{"-x"},
+			[]string{"-ldflags", "-X main.V=1", "-tags", "release", "-trimpath", "-x"}},
+	}
+	for _, c := range cases {
+		args := commonBindArgs(c.ldflags, c.tags, c.trimpath, c.extraArgs)
+		if !reflect.DeepEqual(args, c.expected) {
+			t.Fatalf("expected %v but got %v", c.expected, args)
+		}
+	}
+}
+
+func TestBindCommands(t *testing.T) {
+	newGoUp := func(targets ...string) *GoUp {
+		g := newTestGoUp(nil)
+		g.args.BaseDir = "/project"
+		g.args.Targets = targets
+		g.config.Name = "app"
+		g.config.Build = &Build{Gomobile: &BuildGomobile{
+			Export:  []string{"example.com/a", "example.com/b"},
+			Android: &Android{Out: "./out/app.aar", Javapkg: "com.example", Tags: []string{"release"}, Trimpath: true},
+			Ios:     &Ios{Prefix: "App", Bundleid: "com.example.app", Ldflags: "-s -w", ExtraArgs: []string{"-x"}},
+		}}
+		return g
+	}
+
+	android := bindCommand{Target: "gomobile/android", Args: []string{"bind", "-v", "-o", "/project/out/app.aar",
+		"-javapkg", "com.example", "-target=android", "-tags", "release", "-trimpath",
+		"example.com/a", "example.com/b"}}
+	ios := bindCommand{Target: "gomobile/ios", Args: []string{"bind", "-v", "-o", "/project/app.framework",
+		"-prefix", "App", "-bundleid", "com.example.app", "-target=ios", "-ldflags", "-s -w", "-x",
+		"example.com/a", "example.com/b"}}
+
+	if cmds := newGoUp("all").bindCommands(); !reflect.DeepEqual(cmds, []bindCommand{android, ios}) {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+	if cmds := newGoUp("gomobile/ios").bindCommands(); !reflect.DeepEqual(cmds, []bindCommand{ios}) {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+
+	// disabled targets are skipped, even if selected
+	g := newGoUp("all")
+	g.config.Build.Gomobile.Android.Disabled = true
+	if cmds := g.bindCommands(); !reflect.DeepEqual(cmds, []bindCommand{ios}) {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+	g.config.Build.Gomobile.Ios.Disabled = true
+	if cmds := g.bindCommands(); len(cmds) != 0 {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+
+	// the api level and abis narrow the android targets
+	g = newGoUp("gomobile/android")
+	g.config.Build.Gomobile.Android.Api = 21
+	g.config.Build.Gomobile.Android.Abis = []string{"arm64-v8a", "x86_64"}
+	android.Args = []string{"bind", "-v", "-o", "/project/out/app.aar", "-javapkg", "com.example",
+		"-target=android/arm64,android/amd64", "-androidapi", "21", "-tags", "release", "-trimpath",
+		"example.com/a", "example.com/b"}
+	if cmds := g.bindCommands(); !reflect.DeepEqual(cmds, []bindCommand{android}) {
+		t.Fatalf("unexpected commands %v", cmds)
+	}
+}
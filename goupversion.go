@@ -0,0 +1,101 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// versionOperators are the supported comparisons, longest first so that >= is not taken as >
+var versionOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// A versionConstraint is a single comparison like >=0.0.24
+type versionConstraint struct {
+	op      string
+	version Version
+}
+
+// matches checks if the version satisfies the constraint
+func (c versionConstraint) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	default:
+		return cmp == 0
+	}
+}
+
+// parseVersionConstraints parses comma separated comparisons like ">=0.0.24, <0.1.0". A version without an
+// operator must match exactly.
+func parseVersionConstraints(str string) ([]versionConstraint, error) {
+	var res []versionConstraint
+	for _, token := range strings.Split(str, ",") {
+		token = strings.TrimSpace(token)
+		c := versionConstraint{op: "="}
+		for _, op := range versionOperators {
+			if strings.HasPrefix(token, op) {
+				c.op = op
+				token = strings.TrimSpace(token[len(op):])
+				break
+			}
+		}
+		v, err := ParseSemanticVersion(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint '%s': %v", str, err)
+		}
+		c.version = v
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// checkGoUpVersion fails, if the running version does not satisfy the constraints of goup_version. The error
+// recommends a wrapper version, if the constraints declare a lower bound.
+func (c *GoUpConfiguration) checkGoUpVersion() error {
+	if IsEmpty(c.Goup_version) {
+		return nil
+	}
+	constraints, err := parseVersionConstraints(c.Goup_version)
+	if err != nil {
+		return c.locate(ConfigError{Field: "goup_version", Message: err.Error()})
+	}
+	current, err := ParseSemanticVersion(version)
+	must(err)
+
+	for _, constraint := range constraints {
+		if constraint.matches(current) {
+			continue
+		}
+		hint := "use a matching wrapper, e.g. by: goup wrapper -version <version>"
+		for _, lower := range constraints {
+			if lower.op == ">=" || lower.op == "=" {
+				hint = "use a matching wrapper, e.g. by: goup wrapper -version " + lower.version.String()
+			}
+		}
+		msg := fmt.Sprintf("requires %s %s, but this is %s %s, %s", goUp, c.Goup_version, goUp, version, hint)
+		return c.locate(ConfigError{Field: "goup_version", Message: msg})
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestVersionConstraints(t *testing.T) {
+	tests := []struct {
+		constraints string
+		version     string
+		matches     bool
+	}{
+		{">=0.0.24", "0.0.24", true},
+		{">=0.0.24", "0.0.23", false},
+		{">=0.0.24", "0.1.0", true},
+		{">=0.0.24, <0.1.0", "0.1.0", false},
+		{">0.0.24,<=0.1.0", "0.1.0", true},
+		{"0.0.24", "v0.0.24", true},
+		{"=v0.0.24", "0.0.25", false},
+		{"!=0.0.24", "0.0.25", true},
+		{"<1.0.0", "0.9.99", true},
+		{"<1.0.0", "1.0.0", false},
+	}
+	for _, test := range tests {
+		constraints, err := parseVersionConstraints(test.constraints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := ParseSemanticVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		matches := true
+		for _, c := range constraints {
+			matches = matches && c.matches(v)
+		}
+		if matches != test.matches {
+			t.Fatal(test.constraints, test.version, "expected", test.matches, "but got", matches)
+		}
+	}
+
+	for _, invalid := range []string{"", "~0.1", ">=0.1", ">=0.0.24,"} {
+		if _, err := parseVersionConstraints(invalid); err == nil {
+			t.Fatal("expected an error for", invalid)
+		}
+	}
+}
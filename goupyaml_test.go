@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsGet(t *testing.T) {
+	timeouts := &Timeouts{Toolchain: "1h30m", Vendor: "90s", Bind: "", Before_script: "250ms", Test: "2m"}
+	cases := map[string]time.Duration{
+		phaseToolchain:    90 * time.Minute,
+		phaseVendor:       90 * time.Second,
+		phaseBind:         0,
+		phaseBeforeScript: 250 * time.Millisecond,
+		phaseTest:         2 * time.Minute,
+		"unknown":         0,
+	}
+	for phase, expected := range cases {
+		d, err := timeouts.Get(phase)
+		if err != nil {
+			t.Fatalf("%s: %v", phase, err)
+		}
+		if d != expected {
+			t.Fatalf("%s: expected %v but got %v", phase, expected, d)
+		}
+	}
+
+	// no timeouts section at all
+	var none *Timeouts
+	if d, err := none.Get(phaseBind); d != 0 || err != nil {
+		t.Fatalf("expected no limit but got %v %v", d, err)
+	}
+}
+
+func TestTimeoutsGetInvalid(t *testing.T) {
+	for _, str := range []string{"10", "1x", "ten minutes"} {
+		_, err := (&Timeouts{Vendor: str}).Get(phaseVendor)
+		if err == nil || !strings.Contains(err.Error(), "invalid timeout for vendor") {
+			t.Fatalf("%s: unexpected error %v", str, err)
+		}
+	}
+}
+
+func TestLoadName(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		yaml     string
+		expected string
+	}{
+		// includes, secrets and unknown keys are not resolved
+		{"name: app\ninclude:\n  - https://example.com/missing.yaml\nsecrets:\n  - name: TOKEN\n    command: false\nunknown: true\n", "app"},
+		{"name: app-${FLAVOR}\n", "app-free"},
+		{"name: ''\n", ""},
+		{"name: ../app\n", ""},
+		{"name: ${MISSING}\n", ""},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, "goup.yaml")
+		if err := ioutil.WriteFile(file, []byte(test.yaml), 0644); err != nil {
+			t.Fatal(err)
+		}
+		name, err := LoadName(Path(file), map[string]string{"FLAVOR": "free"})
+		if len(test.expected) == 0 {
+			if err == nil {
+				t.Fatalf("%q: expected an error but got %s", test.yaml, name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(test.yaml, err)
+		}
+		if name != test.expected {
+			t.Fatalf("%q: expected %s but got %s", test.yaml, test.expected, name)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A goReplace is a replace directive of a go.mod or go.work file, e.g. example.com/x v1.0.0 => ../x
+type goReplace struct {
+	// Old is the replaced module path
+	Old string
+	// OldVersion restricts the replacement to a single version, if not empty
+	OldVersion string
+	// New is either a module path or a local directory
+	New string
+	// NewVersion is the version of New, which is empty for a local directory
+	NewVersion string
+}
+
+// isLocal returns true, if the replacement is a directory. Like go, only absolute paths and paths starting
+// with ./ or ../ are directories.
+func (r goReplace) isLocal() bool {
+	return filepath.IsAbs(r.New) || strings.HasPrefix(r.New, "./") || strings.HasPrefix(r.New, "../") ||
+		strings.HasPrefix(r.New, `.\`) || strings.HasPrefix(r.New, `..\`)
+}
+
+// editArg returns the argument of go mod edit -replace, e.g. example.com/x@v1.0.0=../x
+func (r goReplace) editArg() string {
+	return joinVersion(r.Old, r.OldVersion) + "=" + joinVersion(r.New, r.NewVersion)
+}
+
+// String returns the directive like it is written in go.mod
+func (r goReplace) String() string {
+	newPath := r.New
+	if r.isLocal() {
+		newPath = strconv.Quote(r.New)
+	}
+	return strings.TrimSpace(r.Old+" "+r.OldVersion) + " => " + strings.TrimSpace(newPath+" "+r.NewVersion)
+}
+
+// joinVersion returns path@version or just the path, if there is no version
+func joinVersion(path string, version string) string {
+	if len(version) == 0 {
+		return path
+	}
+	return path + "@" + version
+}
+
+// A goModFile contains the directives of a go.mod or go.work file, which are relevant for GoUp
+type goModFile struct {
+	// Module is the name of the module, empty for go.work
+	Module string
+	// Use contains the module directories, relative to the go.work file
+	Use []string
+	// Replace contains the replace directives, which apply to all modules of a workspace
+	Replace []goReplace
+}
+
+// parseGoModFile reads the module, use and replace directives of a go.mod or go.work file, either as single
+// lines or as blocks. Other directives like go, require or toolchain are ignored.
+func parseGoModFile(data []byte) (*goModFile, error) {
+	work := &goModFile{}
+	block := ""
+	for i, line := range strings.Split(string(data), "\n") {
+		tokens, err := goModTokens(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		verb := block
+		switch {
+		case len(block) > 0 && tokens[0] == ")":
+			block = ""
+			continue
+		case len(block) == 0 && len(tokens) == 2 && tokens[1] == "(":
+			block = tokens[0]
+			continue
+		case len(block) == 0:
+			verb, tokens = tokens[0], tokens[1:]
+		}
+
+		switch verb {
+		case "module":
+			if len(tokens) != 1 {
+				return nil, fmt.Errorf("line %d: expected a single module path", i+1)
+			}
+			work.Module = tokens[0]
+		case "use":
+			if len(tokens) != 1 {
+				return nil, fmt.Errorf("line %d: expected a single directory in use", i+1)
+			}
+			work.Use = append(work.Use, tokens[0])
+		case "replace":
+			r, err := parseGoReplace(tokens)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
+			}
+			work.Replace = append(work.Replace, r)
+		}
+	}
+	if len(block) > 0 {
+		return nil, fmt.Errorf("unterminated %s block", block)
+	}
+	return work, nil
+}
+
+// parseGoReplace parses the tokens of a replace directive without the verb, e.g. [example.com/x v1.0.0 => ../x]
+func parseGoReplace(tokens []string) (goReplace, error) {
+	arrow := -1
+	for i, token := range tokens {
+		if token == "=>" {
+			arrow = i
+		}
+	}
+	if arrow < 1 || arrow > 2 || len(tokens)-arrow-1 < 1 || len(tokens)-arrow-1 > 2 {
+		return goReplace{}, fmt.Errorf("expected 'module [version] => path [version]' in replace, but got '%s'", strings.Join(tokens, " "))
+	}
+
+	r := goReplace{Old: tokens[0], New: tokens[arrow+1]}
+	if arrow == 2 {
+		r.OldVersion = tokens[1]
+	}
+	if len(tokens) == arrow+3 {
+		r.NewVersion = tokens[arrow+2]
+	}
+	if r.isLocal() && len(r.NewVersion) > 0 {
+		return goReplace{}, fmt.Errorf("the directory %s must not have a version", r.New)
+	}
+	if !r.isLocal() && len(r.NewVersion) == 0 {
+		return goReplace{}, fmt.Errorf("the module %s requires a version, directories must start with ./ or ../", r.New)
+	}
+	return r, nil
+}
+
+// goModTokens splits a line of a go.mod or go.work file into its tokens, without the comment. Strings may be
+// quoted by " or `.
+func goModTokens(line string) ([]string, error) {
+	tokens := make([]string, 0)
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(line[i:], "//"):
+			return tokens, nil
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if c == '"' && line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			str, err := strconv.Unquote(line[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %v", line[i:end+1], err)
+			}
+			tokens = append(tokens, str)
+			i = end + 1
+		default:
+			end := strings.IndexAny(line[i:], " \t\r\"`")
+			if end < 0 {
+				end = len(line) - i
+			}
+			if idx := strings.Index(line[i:i+end], "//"); idx >= 0 {
+				end = idx
+			}
+			tokens = append(tokens, line[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// localReplaces returns the replace directives of the go.mod file in the module directory. Replacements by
+// directories are resolved relative to the module directory, because they would break in a copy.
+func localReplaces(dir Path) ([]goReplace, error) {
+	data, err := ioutil.ReadFile(dir.Child("go.mod").String())
+	if err != nil {
+		return nil, err
+	}
+	mod, err := parseGoModFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", dir.Child("go.mod"), err)
+	}
+	for i, r := range mod.Replace {
+		if r.isLocal() {
+			mod.Replace[i].New = Path(r.New).Resolve(dir).String()
+		}
+	}
+	return mod.Replace, nil
+}
+
+// mergeReplaces merges the replace directives in the order of their precedence, the first replacement of a module
+// wins. A losing replacement is logged, because its module is not built with the code it declares.
+func mergeReplaces(groups ...[]goReplace) []goReplace {
+	replaces := make([]goReplace, 0)
+	replaced := make(map[string]goReplace)
+	for _, group := range groups {
+		for _, r := range group {
+			key := joinVersion(r.Old, r.OldVersion)
+			if winner, ok := replaced[key]; ok {
+				if winner != r {
+					logger.Warn(Fields{"msg": "replace directive ignored", "replace": r.String(), "winner": winner.String()})
+				}
+				continue
+			}
+			replaced[key] = r
+			replaces = append(replaces, r)
+		}
+	}
+	return replaces
+}
+
+// loadWorkspace adds the modules of the declared go.work file to the modules and remembers its replace
+// directives, including the replacement of each used module by its directory, so that the modules of the
+// workspace resolve each other locally, just like go does.
+func (c *GoUpConfiguration) loadWorkspace(baseDir Path) error {
+	if c.Build == nil || c.Build.Gomobile == nil || len(c.Build.Gomobile.Workspace) == 0 {
+		return nil
+	}
+	b := c.Build.Gomobile
+	fail := func(format string, args ...interface{}) error {
+		return c.locate(ConfigError{Field: "build.gomobile.workspace", Message: fmt.Sprintf(format, args...)})
+	}
+
+	file := b.Workspace.Resolve(baseDir)
+	data, err := ioutil.ReadFile(file.String())
+	if err != nil {
+		return fail("failed to read workspace: %v", err)
+	}
+	work, err := parseGoModFile(data)
+	if err != nil {
+		return fail("%s: %v", file, err)
+	}
+	declared := make(map[Path]bool)
+	for _, mod := range b.Modules {
+		declared[Path(mod).Resolve(baseDir)] = true
+	}
+
+	b.replaces = nil
+	for _, use := range work.Use {
+		dir := Path(use).Resolve(file.Parent())
+		modName, err := getModuleName(dir.Child("go.mod"))
+		if err != nil {
+			return fail("%s: use %s: not a go module: %v", file, use, err)
+		}
+		b.replaces = append(b.replaces, goReplace{Old: modName, New: dir.String()})
+		if !declared[dir] {
+			declared[dir] = true
+			b.Modules = append(b.Modules, ModuleSpecifier(dir))
+		}
+	}
+
+	for _, r := range work.Replace {
+		if r.isLocal() {
+			r.New = Path(r.New).Resolve(file.Parent()).String()
+		}
+		b.replaces = append(b.replaces, r)
+	}
+	logger.Debug(Fields{"workspace": file, "modules": b.Modules, "replaces": b.replaces})
+	return nil
+}
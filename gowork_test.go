@@ -0,0 +1,158 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGoWork(t *testing.T) {
+	work, err := parseGoModFile([]byte(`go 1.21 // comment
+
+toolchain go1.21.5
+
+use ./app
+use (
+	./lib // the library
+	"./with space"
+	` + "`./raw`" + `
+)
+
+replace example.com/ext => ../ext
+replace (
+	example.com/old v1.2.3 => example.com/new v1.2.4
+	example.com/win => .\win
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"./app", "./lib", "./with space", "./raw"}; !reflect.DeepEqual(work.Use, expected) {
+		t.Fatalf("expected %v but got %v", expected, work.Use)
+	}
+	expected := []goReplace{
+		{Old: "example.com/ext", New: "../ext"},
+		{Old: "example.com/old", OldVersion: "v1.2.3", New: "example.com/new", NewVersion: "v1.2.4"},
+		{Old: "example.com/win", New: `.\win`},
+	}
+	if !reflect.DeepEqual(work.Replace, expected) {
+		t.Fatalf("expected %v but got %v", expected, work.Replace)
+	}
+	if arg := work.Replace[1].editArg(); arg != "example.com/old@v1.2.3=example.com/new@v1.2.4" {
+		t.Fatal("unexpected edit arg", arg)
+	}
+}
+
+func TestParseGoWorkErrors(t *testing.T) {
+	cases := map[string]string{
+		"use (\n./a\n":                           "unterminated use block",
+		"use ./a ./b":                            "line 1: expected a single directory",
+		"use \"./a":                              "line 1: unterminated string",
+		"replace example.com/a => example.com/b": "requires a version",
+		"replace example.com/a => ./b v1.0.0":    "must not have a version",
+		"replace example.com/a ./b":              "expected 'module [version] => path [version]'",
+	}
+	for text, msg := range cases {
+		_, err := parseGoModFile([]byte(text))
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected '%s' but got %v", text, msg, err)
+		}
+	}
+}
+
+func TestLoadWorkspace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := Path(dir)
+	for _, mod := range []string{"app", "lib"} {
+		must(os.MkdirAll(base.Child("repo").Child(mod).String(), os.ModePerm))
+		must(ioutil.WriteFile(base.Child("repo").Child(mod).Child("go.mod").String(), []byte("module example.com/"+mod+"\n"), os.ModePerm))
+	}
+	must(ioutil.WriteFile(base.Child("repo").Child("go.work").String(), []byte("use (\n./app\n./lib\n)\nreplace example.com/ext => ./ext\n"), os.ModePerm))
+
+	cfg := &GoUpConfiguration{Build: &Build{Gomobile: &BuildGomobile{Workspace: "./repo/go.work", Modules: []ModuleSpecifier{"./repo/app"}}}}
+	if err := cfg.loadWorkspace(base); err != nil {
+		t.Fatal(err)
+	}
+	b := cfg.Build.Gomobile
+	if expected := []ModuleSpecifier{"./repo/app", ModuleSpecifier(base.Child("repo").Child("lib"))}; !reflect.DeepEqual(b.Modules, expected) {
+		t.Fatalf("expected %v but got %v", expected, b.Modules)
+	}
+	expected := []goReplace{
+		{Old: "example.com/app", New: base.Child("repo").Child("app").String()},
+		{Old: "example.com/lib", New: base.Child("repo").Child("lib").String()},
+		{Old: "example.com/ext", New: base.Child("repo").Child("ext").String()},
+	}
+	if !reflect.DeepEqual(b.replaces, expected) {
+		t.Fatalf("expected %v but got %v", expected, b.replaces)
+	}
+
+	b.Workspace = "./missing/go.work"
+	err = cfg.loadWorkspace(base)
+	if errs, ok := err.(ConfigErrors); !ok || errs[0].Field != "build.gomobile.workspace" {
+		t.Fatal("expected a located error but got", err)
+	}
+}
+
+func TestLocalReplaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mod := Path(dir).Child("app")
+	must(os.MkdirAll(mod.String(), os.ModePerm))
+	must(ioutil.WriteFile(mod.Child("go.mod").String(), []byte(`module example.com/app
+
+require example.com/x v1.0.0
+
+replace (
+	example.com/x => ../x
+	example.com/y v1.0.0 => example.com/fork/y v1.0.1
+)
+`), os.ModePerm))
+
+	replaces, err := localReplaces(mod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []goReplace{
+		{Old: "example.com/x", New: Path(dir).Child("x").String()},
+		{Old: "example.com/y", OldVersion: "v1.0.0", New: "example.com/fork/y", NewVersion: "v1.0.1"},
+	}
+	if !reflect.DeepEqual(replaces, expected) {
+		t.Fatalf("expected %v but got %v", expected, replaces)
+	}
+}
+
+func TestMergeReplaces(t *testing.T) {
+	locals := []goReplace{{Old: "example.com/a", New: "/src/a"}}
+	workspace := []goReplace{
+		{Old: "example.com/a", New: "/src/a"},
+		{Old: "example.com/x", New: "/src/x"},
+	}
+	modules := []goReplace{
+		{Old: "example.com/x", New: "/src/other/x"},
+		{Old: "example.com/y", New: "/src/y"},
+		{Old: "example.com/y", New: "/src/other/y"},
+		{Old: "example.com/y", OldVersion: "v1.0.0", New: "example.com/fork/y", NewVersion: "v1.0.1"},
+	}
+
+	replaces := mergeReplaces(locals, workspace, modules)
+	expected := []goReplace{
+		{Old: "example.com/a", New: "/src/a"},
+		{Old: "example.com/x", New: "/src/x"},
+		{Old: "example.com/y", New: "/src/y"},
+		{Old: "example.com/y", OldVersion: "v1.0.0", New: "example.com/fork/y", NewVersion: "v1.0.1"},
+	}
+	if !reflect.DeepEqual(replaces, expected) {
+		t.Fatalf("expected %v but got %v", expected, replaces)
+	}
+}
@@ -16,6 +16,7 @@ package main
 
 import (
 	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -164,8 +165,8 @@ func Sha256(str string) string {
 	return hex.EncodeToString(t[:])
 }
 
-// DownloadFile gets a large file without memory buffering
-func DownloadFile(url string, dstFile string) error {
+// DownloadFile gets a large file without memory buffering. The download is aborted when ctx is done.
+func DownloadFile(ctx context.Context, url string, dstFile string) error {
 	logger.Debug(Fields{"action": "downloading", "url": url, "dst": dstFile})
 
 	out, err := os.Create(dstFile)
@@ -175,7 +176,11 @@ func DownloadFile(url string, dstFile string) error {
 	defer out.Close()
 
 	// Get the data
-	resp, err := http.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
 	}
@@ -198,6 +203,9 @@ func DownloadFile(url string, dstFile string) error {
 	// Writer the body to file
 	_, err = io.Copy(out, pgReader)
 	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("download of %s timed out: %v", url, err)
+		}
 		return err
 	}
 
@@ -205,11 +213,11 @@ func DownloadFile(url string, dstFile string) error {
 	return nil
 }
 
-func downloadAndUnpack(url string, targetFolder Path) error {
+func downloadAndUnpack(ctx context.Context, url string, targetFolder Path) error {
 	tmpFile := targetFolder.Parent().Child(Sha256(url) + ".tmp")
 	defer os.Remove(tmpFile.String())
 
-	err := DownloadFile(url, tmpFile.String())
+	err := DownloadFile(ctx, url, tmpFile.String())
 	if err != nil {
 		return err
 	}
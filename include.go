@@ -0,0 +1,130 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ResolveIncludes loads all included configurations recursively and merges them into this configuration, as
+// described by GoUpConfiguration.Include. Relative includes are resolved against the including file or url,
+// urls are loaded by fetch.
+func (c *GoUpConfiguration) ResolveIncludes(fetch func(url string) ([]byte, error)) error {
+	return c.resolveIncludes(fetch, []string{string(c.file)})
+}
+
+// resolveIncludes merges the includes, stack contains the including files to detect cycles
+func (c *GoUpConfiguration) resolveIncludes(fetch func(url string) ([]byte, error), stack []string) error {
+	if len(c.Include) == 0 {
+		return nil
+	}
+
+	base := &GoUpConfiguration{}
+	for i, include := range c.Include {
+		field := fmt.Sprintf("include[%d]", i)
+		location, err := resolveInclude(string(c.file), include)
+		if err != nil {
+			return c.locate(ConfigError{Field: field, Message: err.Error()})
+		}
+		for _, s := range stack {
+			if s == location {
+				return c.locate(ConfigError{Field: field, Message: "include cycle: " + strings.Join(append(stack, location), " -> ")})
+			}
+		}
+
+		included := &GoUpConfiguration{}
+		if isURL(location) {
+			data, err := fetch(location)
+			if err != nil {
+				return c.locate(ConfigError{Field: field, Message: err.Error()})
+			}
+			err = included.parse(Path(location), data)
+		} else {
+			err = included.Load(Path(location))
+		}
+		if _, ok := err.(ConfigErrors); ok {
+			return err
+		}
+		if err != nil {
+			return c.locate(ConfigError{Field: field, Message: err.Error()})
+		}
+
+		// each file declares its own constraint, which must hold before its keys are interpreted or merged
+		err = included.checkGoUpVersion()
+		if err != nil {
+			return err
+		}
+
+		err = included.resolveIncludes(fetch, append(stack, location))
+		if err != nil {
+			return err
+		}
+		base.merge(included)
+	}
+	base.merge(c)
+
+	base.Include = nil
+	base.file = c.file
+	*c = *base
+	return nil
+}
+
+// merge overrides this configuration with other
+func (c *GoUpConfiguration) merge(other *GoUpConfiguration) {
+	overlay(reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem(), "", func(field string) bool {
+		_, declared := other.positions[field]
+		return declared
+	}, true)
+	c.decodeErrors = append(c.decodeErrors, other.decodeErrors...)
+
+	// the keys of other are declared as well and located in its file
+	if c.positions == nil {
+		c.positions = make(map[string]yamlPosition)
+	}
+	for key, pos := range other.positions {
+		c.positions[key] = pos
+	}
+}
+
+// resolveInclude returns the absolute file or url of include, which is relative to the including file or url
+func resolveInclude(including string, include string) (string, error) {
+	if isURL(include) {
+		return include, nil
+	}
+	if isURL(including) {
+		base, err := url.Parse(including)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(include)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	if filepath.IsAbs(include) {
+		return filepath.Clean(include), nil
+	}
+	return filepath.Join(filepath.Dir(including), include), nil
+}
+
+// isURL checks if the include refers to a http or https url
+func isURL(include string) bool {
+	return strings.HasPrefix(include, "https://") || strings.HasPrefix(include, "http://")
+}
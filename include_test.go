@@ -0,0 +1,209 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"common/base.yaml": `include: [https://example.com/shared/toolchain.yaml]
+variables:
+  A: base
+  B: base
+before_script:
+  - echo common
+build:
+  gomobile:
+    export: [github.com/worldiety/std]
+    android:
+      abis: [arm, arm64]
+      trimpath: true
+`,
+		"goup.yaml": `name: app
+include:
+  - ./common/base.yaml
+variables:
+  B: app
+before_script:
+  - echo app
+  - echo common
+build:
+  gomobile:
+    toolchain:
+      go: 1.13
+    export: [app]
+    android:
+      abis: [arm64]
+      trimpath: false
+`,
+	}
+	for name, content := range files {
+		must(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), os.ModePerm))
+		must(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+
+	var fetched []string
+	fetch := func(url string) ([]byte, error) {
+		fetched = append(fetched, url)
+		if strings.HasSuffix(url, "toolchain.yaml") {
+			return []byte("include: [ndk.yaml]\nbuild:\n  gomobile:\n    toolchain:\n      go: 1.12.4\n      jdk: 8u212b03\n"), nil
+		}
+		return []byte("build:\n  gomobile:\n    toolchain:\n      ndk: r19c\n"), nil
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(filepath.Join(dir, "goup.yaml"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ResolveIncludes(fetch); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fetched, []string{"https://example.com/shared/toolchain.yaml", "https://example.com/shared/ndk.yaml"}) {
+		t.Fatal("unexpected urls", fetched)
+	}
+	if !reflect.DeepEqual(cfg.Variables, map[string]string{"A": "base", "B": "app"}) {
+		t.Fatal("unexpected variables", cfg.Variables)
+	}
+	// scripts are ordered, so repeated commands are kept
+	if !reflect.DeepEqual(cfg.Before_script, []string{"echo common", "echo app", "echo common"}) {
+		t.Fatal("unexpected before_script", cfg.Before_script)
+	}
+	g := cfg.Build.Gomobile
+	expected := BuildGomobileToolchain{Go: "1.13", Ndk: "r19c", Jdk: "8u212b03"}
+	if g.Toolchain != expected {
+		t.Fatal("unexpected toolchain", g.Toolchain)
+	}
+	if !reflect.DeepEqual(g.Export, []string{"github.com/worldiety/std", "app"}) {
+		t.Fatal("unexpected export", g.Export)
+	}
+	if !reflect.DeepEqual(g.Android.Abis, []string{"arm64"}) || g.Android.Trimpath {
+		t.Fatal("unexpected android", g.Android)
+	}
+	if cfg.Include != nil || cfg.Name != "app" {
+		t.Fatal("unexpected config", cfg)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	must(ioutil.WriteFile(filepath.Join(dir, "goup.yaml"), []byte("name: app\ninclude: [a.yaml]\n"), os.ModePerm))
+	must(ioutil.WriteFile(filepath.Join(dir, "a.yaml"), []byte("include: [goup.yaml]\n"), os.ModePerm))
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(filepath.Join(dir, "goup.yaml"))); err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.ResolveIncludes(nil)
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatal("expected include cycle but got", err)
+	}
+}
+
+func TestIncludeDeclaredValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"goup.yaml": `name: app
+include: [a.yaml]
+build:
+  gomobile:
+    android:
+      disabled: true
+      trimpath: true
+`,
+		"a.yaml": `include: [b.yaml]
+profiles:
+  release:
+    gomobile:
+      android:
+        disabled: false
+`,
+		"b.yaml": `build:
+  gomobile:
+    android:
+      trimpath: false
+`,
+	}
+	for name, content := range files {
+		must(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(filepath.Join(dir, "goup.yaml"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ResolveIncludes(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the profile of an include explicitly enables android again
+	if err := cfg.ApplyProfile("release"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Build.Gomobile.Android.Disabled || !cfg.Build.Gomobile.Android.Trimpath {
+		t.Fatal("unexpected android", cfg.Build.Gomobile.Android)
+	}
+
+	// keys of includes are located within the include
+	pos := cfg.position("profiles.release.gomobile.android.disabled")
+	if pos.File != Path(filepath.Join(dir, "a.yaml")) || pos.Line != 6 || pos.Column != 9 {
+		t.Fatal("unexpected position", pos)
+	}
+	pos = cfg.position("build.gomobile.android.trimpath")
+	if pos.File != Path(filepath.Join(dir, "goup.yaml")) || pos.Line != 7 {
+		t.Fatal("unexpected position", pos)
+	}
+}
+
+func TestIncludeGoUpVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"goup.yaml": "name: app\ngoup_version: \">= 0.0.1\"\ninclude: [a.yaml]\n",
+		"a.yaml":    "include: [b.yaml]\n",
+		"b.yaml":    "goup_version: \">= 99.0.0\"\nkey_of_a_newer_version: true\n",
+	}
+	for name, content := range files {
+		must(ioutil.WriteFile(filepath.Join(dir, name), []byte(content), os.ModePerm))
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(filepath.Join(dir, "goup.yaml"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.checkGoUpVersion(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the constraint of the nested include wins over its unknown keys and is not overridden by the root file
+	err = cfg.ResolveIncludes(nil)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "b.yaml")+":1:") ||
+		!strings.Contains(err.Error(), "requires "+goUp+" >= 99.0.0") {
+		t.Fatal("expected the version constraint of b.yaml but got", err)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// initData contains the values of the init templates
+type initData struct {
+	Name      string
+	Module    string
+	Package   string
+	Prefix    string
+	Javapkg   string
+	Targets   string
+	Android   bool
+	Ios       bool
+	Toolchain toolchainVersions
+}
+
+// initFile is a file created by init
+type initFile struct {
+	name     string
+	template string
+	mode     os.FileMode
+}
+
+// moduleRegex matches a plausible go module path
+var moduleRegex = regexp.MustCompile(`^[a-zA-Z0-9_.~-]+(/[a-zA-Z0-9_.~-]+)*$`)
+
+// InitProject implements the init command, which creates a new project in the base directory: a goup.yaml, a go
+// module with an exported package, the goupw wrapper pinned to this version (see GenerateWrapper) and optionally a gradle module and
+// a script for an xcode build phase. Existing files are only overwritten with -force.
+func InitProject(args *Args, w io.Writer) error {
+	flags := args.FlagSet("init")
+	name := flags.String("name", "", "The name of the project, defaults to the name of the directory.")
+	module := flags.String("module", "", "The path of the go module, defaults to mycompany.com/<name>.")
+	javapkg := flags.String("javapkg", "", "The java package of the android bindings, defaults to com.mycompany.<name>.")
+	targets := flags.String("targets", "gomobile/android:gomobile/ios", "The targets to build, e.g. gomobile/android or gomobile/ios. Can be concated by :")
+	gradle := flags.Bool("gradle", false, "Creates the gradle module appAndroid/libGo, which runs goupw and provides the aar.")
+	xcode := flags.Bool("xcode", false, "Creates appIOS/goup.sh, to run goupw in an xcode build phase.")
+	distributionURL := flags.String("distributionUrl", defaultDistributionURL, "Where the "+goUp+" binaries are downloaded from, as <distributionUrl>/<version>/<os>-<arch>/goup.")
+	force := flags.Bool("force", false, "Overwrites existing files.")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+	if IsEmpty(*name) {
+		*name = args.BaseDir.Name()
+	}
+
+	data := initData{Name: *name, Module: *module, Javapkg: *javapkg, Targets: *targets, Toolchain: defaultToolchainVersions}
+	if IsEmpty(data.Name) || strings.ContainsAny(data.Name, `/\`) {
+		return fmt.Errorf("invalid project name '%s'", data.Name)
+	}
+	data.Package = packageName(data.Name)
+	data.Prefix = strings.Title(data.Package)
+	if IsEmpty(data.Module) {
+		data.Module = "mycompany.com/" + data.Package
+	}
+	if !moduleRegex.MatchString(data.Module) {
+		return fmt.Errorf("invalid module path '%s'", data.Module)
+	}
+	if IsEmpty(data.Javapkg) {
+		data.Javapkg = "com.mycompany." + data.Package
+	}
+	if !javaPackageRegex.MatchString(data.Javapkg) {
+		return fmt.Errorf("invalid java package '%s'", data.Javapkg)
+	}
+	for _, target := range strings.Split(data.Targets, ":") {
+		switch target {
+		case "all":
+			data.Android, data.Ios = true, true
+		case "gomobile/android":
+			data.Android = true
+		case "gomobile/ios":
+			data.Ios = true
+		default:
+			return fmt.Errorf("unknown target '%s'", target)
+		}
+	}
+
+	files := []initFile{
+		{goup + ".yaml", initGoupYAMLTemplate, 0644},
+		{"libGo/go.mod", initGoModTemplate, 0644},
+		{"libGo/" + data.Package + ".go", initGoPackageTemplate, 0644},
+	}
+	if *gradle {
+		files = append(files, initFile{"appAndroid/libGo/build.gradle", initGradleTemplate, 0644})
+	}
+	if *xcode {
+		files = append(files, initFile{"appIOS/goup.sh", initXcodeTemplate, 0755})
+	}
+
+	if !*force {
+		for _, file := range append(files, initFile{name: "goupw"}, initFile{name: wrapperProperties}) {
+			if args.BaseDir.Child(file.name).Exists() {
+				return fmt.Errorf("%s already exists, use -force to overwrite it", args.BaseDir.Child(file.name))
+			}
+		}
+	}
+
+	// the wrapper is pinned to this version, fetch its checksums before anything is written
+	wrapper := wrapperData{Version: "v" + version, Targets: data.Targets, URL: strings.TrimSuffix(*distributionURL, "/"), Properties: wrapperProperties}
+	wrapper.Checksums, err = fetchChecksums(wrapper.URL, wrapper.Version, wrapperPlatforms)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		dst := args.BaseDir.Child(file.name)
+		err := writeTemplate(dst, file.template, data, file.mode)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "created %s\n", dst)
+	}
+	return writeWrapper(args.BaseDir, wrapper, w)
+}
+
+// writeTemplate executes the template with data and writes the result into dst
+func writeTemplate(dst Path, text string, data interface{}, mode os.FileMode) error {
+	tpl, err := template.New(dst.Name()).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse template of %s: %v", dst, err)
+	}
+	buf := &bytes.Buffer{}
+	err = tpl.Execute(buf, data)
+	if err != nil {
+		return fmt.Errorf("failed to execute template of %s: %v", dst, err)
+	}
+	err = os.MkdirAll(filepath.Dir(dst.String()), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(dst.String(), buf.Bytes(), mode)
+	if err != nil {
+		return err
+	}
+	// WriteFile does not change the mode of existing files
+	return os.Chmod(dst.String(), mode)
+}
+
+// packageName converts the project name into a valid go package name, e.g. My-App becomes myapp
+func packageName(name string) string {
+	sb := &strings.Builder{}
+	for _, r := range strings.ToLower(name) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sb.WriteRune(r)
+		}
+	}
+	pkg := sb.String()
+	if len(pkg) == 0 || unicode.IsDigit(rune(pkg[0])) {
+		pkg = "lib" + pkg
+	}
+	return pkg
+}
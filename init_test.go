@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestPackageName(t *testing.T) {
+	for name, expected := range map[string]string{"My-App": "myapp", "app_2": "app2", "42": "lib42", "--": "lib"} {
+		if pkg := packageName(name); pkg != expected {
+			t.Fatal("expected", expected, "but got", pkg)
+		}
+	}
+}
+
+func TestInitProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newDistributionServer("v" + version)
+	defer server.Close()
+
+	args := &Args{BaseDir: Path(dir), CommandArgs: []string{"-name", "demo", "-targets", "gomobile/android", "-distributionUrl", server.URL}}
+	if err := InitProject(args, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(dir).Child("goup.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Build.Gomobile.Ios != nil || cfg.Build.Gomobile.Android.Javapkg != "com.mycompany.demo" {
+		t.Fatal("unexpected configuration", cfg)
+	}
+	for _, file := range []string{"goupw", wrapperProperties, "libGo/go.mod", "libGo/demo.go"} {
+		if !Path(dir).Child(file).Exists() {
+			t.Fatal("expected", file)
+		}
+	}
+
+	if err := InitProject(args, ioutil.Discard); err == nil {
+		t.Fatal("expected an error for existing files")
+	}
+}
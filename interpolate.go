@@ -0,0 +1,177 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// expandVars replaces ${VAR} and ${VAR:-default} by their values. The default is used, if the variable is
+// undefined or empty. A reference to an undefined variable without default is an error. $${ escapes a literal ${,
+// all other $ are kept, so that shell variables like $HOME or $$ keep working in scripts.
+func expandVars(str string, lookup func(name string) (string, bool)) (string, error) {
+	return expand(str, lookup, true)
+}
+
+// expandScriptVars replaces only the references to defined variables. Everything else is left to the shell,
+// e.g. ${PWD##*/} or loop variables like ${f}, as well as ${VAR:-default} of an undefined variable.
+func expandScriptVars(str string, lookup func(name string) (string, bool)) (string, error) {
+	return expand(str, lookup, false)
+}
+
+// expand implements expandVars and, if not strict, expandScriptVars
+func expand(str string, lookup func(name string) (string, bool), strict bool) (string, error) {
+	if !strings.Contains(str, "${") {
+		return str, nil
+	}
+	sb := &strings.Builder{}
+	for i := 0; i < len(str); {
+		if strings.HasPrefix(str[i:], "$${") {
+			sb.WriteString("${")
+			i += 3
+			continue
+		}
+		if !strings.HasPrefix(str[i:], "${") {
+			sb.WriteByte(str[i])
+			i++
+			continue
+		}
+		end := strings.Index(str[i:], "}")
+		if end < 0 {
+			if !strict {
+				sb.WriteString(str[i:])
+				break
+			}
+			return "", fmt.Errorf("unterminated variable reference: %s", str[i:])
+		}
+		expr := str[i+2 : i+end]
+		i += end + 1
+
+		name := expr
+		def, hasDefault := "", false
+		if idx := strings.Index(expr, ":-"); idx >= 0 {
+			name, def, hasDefault = expr[:idx], expr[idx+2:], true
+		}
+		val, ok := "", false
+		if isVarName(name) {
+			val, ok = lookup(name)
+		}
+		if !strict && !ok {
+			sb.WriteString("${" + expr + "}")
+			continue
+		}
+		if !isVarName(name) {
+			return "", fmt.Errorf("invalid variable name: ${%s}", expr)
+		}
+		switch {
+		case ok && len(val) > 0:
+			sb.WriteString(val)
+		case hasDefault:
+			sb.WriteString(def)
+		case ok:
+			// defined but empty
+		default:
+			return "", fmt.Errorf("undefined variable: ${%s}", name)
+		}
+	}
+	return sb.String(), nil
+}
+
+// isVarName checks for [A-Za-z_][A-Za-z0-9_]*
+func isVarName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '_'
+		digit := r >= '0' && r <= '9'
+		if !letter && !(digit && i > 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// interpolate walks recursively through the pointer v and expands the variables of all strings, including
+// string based types like Path, slice elements and map values. Errors are a ConfigError naming the field,
+// e.g. build.gomobile.modules[0]. Fields tagged by interpolate:"script" are expanded like expandScriptVars.
+func interpolate(v interface{}, lookup func(name string) (string, bool)) error {
+	return interpolateValue(reflect.ValueOf(v), "", lookup, true)
+}
+
+func interpolateValue(v reflect.Value, field string, lookup func(name string) (string, bool), strict bool) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return interpolateValue(v.Elem(), field, lookup, strict)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue // unexported
+			}
+			// scripts are interpreted by the shell, which knows more than our variables
+			fieldStrict := strict && v.Type().Field(i).Tag.Get("interpolate") != "script"
+			err := interpolateValue(v.Field(i), joinField(field, yamlName(v.Type().Field(i))), lookup, fieldStrict)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			err := interpolateValue(v.Index(i), fmt.Sprintf("%s[%d]", field, i), lookup, strict)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range v.MapKeys() {
+			val := v.MapIndex(key)
+			expanded, err := expand(val.String(), lookup, strict)
+			if err != nil {
+				return ConfigError{Field: joinField(field, fmt.Sprint(key.Interface())), Message: err.Error()}
+			}
+			v.SetMapIndex(key, reflect.ValueOf(expanded).Convert(val.Type()))
+		}
+	case reflect.String:
+		expanded, err := expand(v.String(), lookup, strict)
+		if err != nil {
+			return ConfigError{Field: field, Message: err.Error()}
+		}
+		v.SetString(expanded)
+	}
+	return nil
+}
+
+// yamlName returns the key of a struct field in goup.yaml
+func yamlName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("yaml"), ",")[0]; len(tag) > 0 {
+		return tag
+	}
+	return strings.ToLower(field.Name)
+}
+
+func joinField(parent string, name string) string {
+	if len(parent) == 0 {
+		return name
+	}
+	return parent + "." + name
+}
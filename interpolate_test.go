@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{"A": "a", "EMPTY": "", "URL": "https://x?a=b"}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"${A}", "a"},
+		{"x-${A}-${A}", "x-a-a"},
+		{"${URL}", "https://x?a=b"},
+		{"${MISSING:-def}", "def"},
+		{"${EMPTY:-def}", "def"},
+		{"${A:-def}", "a"},
+		{"[${EMPTY}]", "[]"},
+		{"$$ $HOME $${A}", "$$ $HOME ${A}"},
+	}
+	for _, c := range cases {
+		got, err := expandVars(c.in, lookup)
+		if err != nil {
+			t.Fatal(c.in, err)
+		}
+		if got != c.want {
+			t.Fatalf("%s: expected %s but got %s", c.in, c.want, got)
+		}
+	}
+
+	for _, invalid := range []string{"${MISSING}", "${A", "${1A}", "${}"} {
+		if _, err := expandVars(invalid, lookup); err == nil {
+			t.Fatal("expected error for", invalid)
+		}
+	}
+}
+
+func TestInterpolateConfig(t *testing.T) {
+	cfg := &GoUpConfiguration{
+		Name:      "${NAME}",
+		Variables: map[string]string{"X": "${NAME}-x"},
+		Build: &Build{Gomobile: &BuildGomobile{
+			Android: &Android{Out: "./out/${NAME}.aar", Javapkg: "com.${ORG:-example}"},
+			Modules: []ModuleSpecifier{"./${NAME}"},
+		}},
+	}
+	err := interpolate(cfg, func(name string) (string, bool) {
+		if name == "NAME" {
+			return "app", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "app" || cfg.Variables["X"] != "app-x" || cfg.Build.Gomobile.Android.Out != "./out/app.aar" ||
+		cfg.Build.Gomobile.Android.Javapkg != "com.example" || cfg.Build.Gomobile.Modules[0] != "./app" {
+		t.Fatal("unexpected interpolation", cfg.String())
+	}
+
+	cfg.Build.Gomobile.Export = []string{"${NOPE}"}
+	err = interpolate(cfg, func(name string) (string, bool) { return "", false })
+	if err == nil || err.Error() != "build.gomobile.export[0]: undefined variable: ${NOPE}" {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+func TestExpandScriptVars(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "A" {
+			return "a", true
+		}
+		return "", false
+	}
+	cases := map[string]string{
+		"echo ${A}":                      "echo a",
+		"cd ${PWD##*/}":                  "cd ${PWD##*/}",
+		"echo ${VAR#x} ${VAR%.go}":       "echo ${VAR#x} ${VAR%.go}",
+		"for f in *; do echo ${f}; done": "for f in *; do echo ${f}; done",
+		"echo ${MISSING:-def} ${A:-def}": "echo ${MISSING:-def} a",
+		"echo $${A} ${A} $HOME ${#A}":    "echo ${A} a $HOME ${#A}",
+		"echo ${A} ${":                   "echo a ${",
+	}
+	for in, expected := range cases {
+		got, err := expandScriptVars(in, lookup)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != expected {
+			t.Fatalf("%s: expected %s but got %s", in, expected, got)
+		}
+	}
+}
+
+func TestInterpolateBeforeScript(t *testing.T) {
+	cfg := &GoUpConfiguration{
+		Name:          "${NAME}",
+		Before_script: []string{"cd ${NAME}", "echo ${PWD##*/}", "for f in *; do echo ${f}; done"},
+	}
+	err := interpolate(cfg, func(name string) (string, bool) {
+		if name == "NAME" {
+			return "app", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cd app", "echo ${PWD##*/}", "for f in *; do echo ${f}; done"}
+	if !reflect.DeepEqual(cfg.Before_script, expected) {
+		t.Fatalf("expected %v but got %v", expected, cfg.Before_script)
+	}
+}
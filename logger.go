@@ -108,5 +108,5 @@ func (l *defaultLogger) log(fields map[string]interface{}) {
 	for _, s := range tmp {
 		sb.WriteString(s)
 	}
-	fmt.Println(sb.String())
+	fmt.Println(redactor.Redact(sb.String()))
 }
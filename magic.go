@@ -18,3 +18,4 @@ const version = "0.0.24"
 const goUp = "GoUp"
 const goup = "goup"
 const defaultResourcesURL = "https://raw.githubusercontent.com/worldiety/goup/master/resources.xml"
+const defaultDistributionURL = "https://cdn.worldiety.org/github.com/worldiety/goup"
@@ -0,0 +1,247 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// the build strategies of build.gomobile.mode
+const (
+	// modeGopath vendors all modules into an emulated GOPATH and binds with GO111MODULE=off
+	modeGopath = "gopath"
+	// modeModules binds with GO111MODULE=on from a generated main module, which replaces the local modules
+	modeModules = "modules"
+)
+
+// minModulesGoVersion is the first go version, which gomobile supports in module mode
+var minModulesGoVersion = Version{Major: 1, Minor: 16}
+
+// goVersionRegex matches the major and minor part of go versions like 1.12.4, 1.17 or 1.21rc2
+var goVersionRegex = regexp.MustCompile(`^(\d+)\.(\d+)`)
+
+// parseGoVersion returns the major and minor version of a go toolchain version
+func parseGoVersion(str string) (Version, bool) {
+	m := goVersionRegex.FindStringSubmatch(strings.TrimPrefix(strings.TrimSpace(str), "go"))
+	if m == nil {
+		return Version{}, false
+	}
+	major, _ := strconv.ParseInt(m[1], 10, 64)
+	minor, _ := strconv.ParseInt(m[2], 10, 64)
+	return Version{Major: major, Minor: minor}, true
+}
+
+// moduleMode returns true, if the modules are bound in module mode instead of the emulated GOPATH
+func (b *BuildGomobile) moduleMode() bool {
+	return b.Mode == modeModules
+}
+
+// validateMode checks the mode and whether the go toolchain supports it
+func (b *BuildGomobile) validateMode(add func(field string, format string, args ...interface{})) {
+	switch b.Mode {
+	case "", modeGopath:
+	case modeModules:
+		goVersion := b.Toolchain.Go
+		if IsEmpty(goVersion) {
+			goVersion = defaultToolchainVersions.Go
+		}
+		v, ok := parseGoVersion(goVersion)
+		if !ok {
+			add("build.gomobile.toolchain.go", "is not a valid go version: '%s'", goVersion)
+			return
+		}
+		if v.Compare(minModulesGoVersion) < 0 {
+			add("build.gomobile.mode", "requires go %d.%d or newer, but the toolchain is go %s. Use mode gopath for older toolchains.",
+				minModulesGoVersion.Major, minModulesGoVersion.Minor, goVersion)
+		}
+	default:
+		add("build.gomobile.mode", "unknown mode '%s', expected %s or %s", b.Mode, modeGopath, modeModules)
+	}
+}
+
+// mainModulePath returns the folder of the generated main module
+func (g *GoUp) mainModulePath() Path {
+	return g.buildDir.Child("module")
+}
+
+// bindPath returns the folder in which gomobile is executed
+func (g *GoUp) bindPath() Path {
+	if g.config.Build.Gomobile.moduleMode() {
+		return g.mainModulePath()
+	}
+	return g.goPath()
+}
+
+// applyModeEnv sets GO111MODULE for the bind, according to the mode
+func (g *GoUp) applyModeEnv() {
+	if g.config.Build.Gomobile.moduleMode() {
+		g.setEnv("GO111MODULE", "on")
+	} else {
+		g.setEnv("GO111MODULE", "off")
+	}
+}
+
+// prepareBindWorkspace makes the modules available to gomobile, either as a generated main module or by
+// vendoring everything into the emulated GOPATH
+func (g *GoUp) prepareBindWorkspace() error {
+	if g.config.Build.Gomobile.moduleMode() {
+		return g.prepareMainModule()
+	}
+	return g.copyModulesToWorkspace()
+}
+
+// prepareMainModule generates a main module, which requires the exported packages and replaces each local
+// module by its original location. The replace directives of the local modules are moved into the main module.
+// Remote modules are added by go get and go mod tidy resolves everything else, just like for any other module.
+func (g *GoUp) prepareMainModule() error {
+	dir := g.mainModulePath()
+	logger.Debug(Fields{"action": "generating main module", "path": dir})
+	err := os.RemoveAll(dir.String())
+	if err != nil {
+		return fmt.Errorf("failed to clear directory %s: %v", dir, err)
+	}
+	err = os.MkdirAll(dir.String(), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+
+	moduleReplaces := make([]goReplace, 0)
+	remotes := make([]string, 0)
+	locals := make([]string, 0)
+	localDirs := make([]goReplace, 0)
+	for _, modPath := range g.config.Build.Gomobile.Modules {
+		resolvedPath := Path(modPath).Resolve(g.args.BaseDir)
+		if !resolvedPath.Exists() {
+			remotes = append(remotes, string(modPath))
+			continue
+		}
+		modName, err := getModuleName(resolvedPath.Child("go.mod"))
+		if err != nil {
+			return fmt.Errorf("expected '%s' to have a go.mod file. This is not a go module: %v", resolvedPath, err)
+		}
+		localDirs = append(localDirs, goReplace{Old: modName, New: resolvedPath.String()})
+		locals = append(locals, modName)
+
+		local, err := localReplaces(resolvedPath)
+		if err != nil {
+			return fmt.Errorf("failed to read replace directives: %v", err)
+		}
+		moduleReplaces = append(moduleReplaces, local...)
+	}
+
+	// only the replace directives of the main module are applied by go. Like in a go workspace, the replacements
+	// of the workspace win over those of the modules
+	replaces := mergeReplaces(localDirs, g.config.Build.Gomobile.replaces, moduleReplaces)
+
+	// the bind package must match the provisioned gomobile, otherwise go mod tidy picks the latest one
+	lines, err := g.run("go", "version", "-m", g.goPath().Child("bin").Child("gomobile").String())
+	if err != nil {
+		return fmt.Errorf("failed to read the version of gomobile: %v", err)
+	}
+	mobileVersion, ok := binaryModuleVersion(lines, "golang.org/x/mobile")
+	if !ok {
+		logger.Warn(Fields{"msg": "cannot pin golang.org/x/mobile, because the version of gomobile is unknown"})
+	}
+
+	goVersion, _ := parseGoVersion(g.toolchainVersions().Go)
+	err = ioutil.WriteFile(dir.Child("go.mod").String(), []byte(mainModuleGoMod(goVersion, mobileVersion, replaces)), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write go.mod: %v", err)
+	}
+	err = ioutil.WriteFile(dir.Child("bind.go").String(), []byte(mainModuleSource(g.config.Build.Gomobile.Export)), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("failed to write bind.go: %v", err)
+	}
+
+	g.chdir(dir)
+	g.setEnv("GO111MODULE", "on")
+	for _, remote := range remotes {
+		_, err := g.run("go", "get", remote)
+		if err != nil {
+			return fmt.Errorf("failed to get module %s: %v", remote, err)
+		}
+	}
+	_, err = g.run("go", "mod", "tidy")
+	if err != nil {
+		return fmt.Errorf("failed to resolve module dependencies: %v", err)
+	}
+
+	// go already selected the versions, but each local module may have been developed with others
+	lines, err = g.run("go", "mod", "graph")
+	if err != nil {
+		return fmt.Errorf("failed to read module graph: %v", err)
+	}
+	graph, err := parseModuleGraph(lines)
+	if err != nil {
+		return fmt.Errorf("failed to parse module graph: %v", err)
+	}
+	requested := make(map[string]map[string]Version)
+	for _, modName := range locals {
+		requested[modName] = graph.buildList(graph.versionsOf(modName)...)
+	}
+	return g.reportConflicts(dependencyConflicts(graph.buildList(graph.mainModules()...), requested))
+}
+
+// binaryModuleVersion returns the version of the module, as printed by go version -m for a binary, which has been
+// built in module mode. Binaries, which have been built in a GOPATH, have no module versions.
+func binaryModuleVersion(lines []string, module string) (string, bool) {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && (fields[0] == "mod" || fields[0] == "dep") && fields[1] == module && fields[2] != "(devel)" {
+			return fields[2], true
+		}
+	}
+	return "", false
+}
+
+// mainModuleGoMod returns the go.mod of the generated main module. If mobileVersion is not empty, the gomobile
+// runtime is required in that version.
+func mainModuleGoMod(goVersion Version, mobileVersion string, replaces []goReplace) string {
+	sb := &strings.Builder{}
+	sb.WriteString("// Code generated by " + goup + ". DO NOT EDIT.\n\n")
+	sb.WriteString("module " + goup + ".local/bind\n\n")
+	sb.WriteString(fmt.Sprintf("go %d.%d\n", goVersion.Major, goVersion.Minor))
+	if len(mobileVersion) > 0 {
+		sb.WriteString("\nrequire golang.org/x/mobile " + mobileVersion + "\n")
+	}
+	if len(replaces) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, r := range replaces {
+		sb.WriteString("replace " + r.String() + "\n")
+	}
+	return sb.String()
+}
+
+// mainModuleSource returns a go file, which imports the exported packages and the gomobile runtime, so that go mod
+// tidy keeps them
+func mainModuleSource(exports []string) string {
+	sb := &strings.Builder{}
+	sb.WriteString("// Code generated by " + goup + ". DO NOT EDIT.\n\n")
+	sb.WriteString("//go:build tools\n// +build tools\n\n")
+	sb.WriteString("package bind\n\n")
+	sb.WriteString("import (\n")
+	sb.WriteString("\t_ \"golang.org/x/mobile/bind\"\n")
+	for _, pkg := range exports {
+		sb.WriteString("\t_ " + strconv.Quote(pkg) + "\n")
+	}
+	sb.WriteString(")\n")
+	return sb.String()
+}
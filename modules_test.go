@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	cases := map[string]Version{"1.12.4": {Major: 1, Minor: 12}, "1.17": {Major: 1, Minor: 17}, "go1.21rc2": {Major: 1, Minor: 21}}
+	for str, expected := range cases {
+		v, ok := parseGoVersion(str)
+		if !ok || v != expected {
+			t.Fatalf("%s: expected %v but got %v", str, expected, v)
+		}
+	}
+	if _, ok := parseGoVersion("tip"); ok {
+		t.Fatal("expected tip to be invalid")
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	cases := []struct {
+		mode    string
+		goVer   string
+		problem string
+	}{
+		{"", "1.12.4", ""},
+		{"gopath", "1.12.4", ""},
+		{"modules", "1.17.1", ""},
+		{"modules", "1.12.4", "requires go 1.16 or newer"},
+		{"modules", "", "requires go 1.16 or newer"},
+		{"modules", "tip", "is not a valid go version: 'tip'"},
+		{"vendor", "1.17.1", "unknown mode 'vendor'"},
+	}
+	for _, c := range cases {
+		b := &BuildGomobile{Mode: c.mode, Toolchain: BuildGomobileToolchain{Go: c.goVer}}
+		var problems []string
+		b.validateMode(func(field string, format string, args ...interface{}) {
+			problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+		})
+		if len(c.problem) == 0 && len(problems) > 0 {
+			t.Fatalf("%s/%s: unexpected problems %v", c.mode, c.goVer, problems)
+		}
+		if len(c.problem) > 0 && (len(problems) != 1 || !strings.Contains(problems[0], c.problem)) {
+			t.Fatalf("%s/%s: expected a problem but got %v", c.mode, c.goVer, problems)
+		}
+	}
+}
+
+func TestMainModule(t *testing.T) {
+	replaces := []goReplace{
+		{Old: "example.com/a", New: "/my src/a"},
+		{Old: "example.com/b", New: "/src/b"},
+		{Old: "example.com/c", OldVersion: "v1.0.0", New: "example.com/fork/c", NewVersion: "v1.0.1"},
+	}
+	expected := `// Code generated by goup. DO NOT EDIT.
+
+module goup.local/bind
+
+go 1.17
+
+require golang.org/x/mobile v0.0.0-20231127183840-76ac6878050a
+
+replace example.com/a => "/my src/a"
+replace example.com/b => "/src/b"
+replace example.com/c v1.0.0 => example.com/fork/c v1.0.1
+`
+	if str := mainModuleGoMod(Version{Major: 1, Minor: 17}, "v0.0.0-20231127183840-76ac6878050a", replaces); str != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, str)
+	}
+
+	src := mainModuleSource([]string{"example.com/a/api"})
+	if !strings.Contains(src, "\t_ \"golang.org/x/mobile/bind\"\n\t_ \"example.com/a/api\"\n") {
+		t.Fatalf("unexpected source\n%s", src)
+	}
+}
+
+func TestBinaryModuleVersion(t *testing.T) {
+	lines := []string{
+		"/home/.goup/app/go/bin/gomobile: go1.21.5",
+		"\tpath\tgolang.org/x/mobile/cmd/gomobile",
+		"\tmod\tgolang.org/x/mobile\tv0.0.0-20231127183840-76ac6878050a\th1:abc=",
+		"\tdep\tgolang.org/x/mod\tv0.14.0\th1:def=",
+	}
+	if v, ok := binaryModuleVersion(lines, "golang.org/x/mobile"); !ok || v != "v0.0.0-20231127183840-76ac6878050a" {
+		t.Fatalf("unexpected version %s", v)
+	}
+	if v, ok := binaryModuleVersion(lines, "golang.org/x/mod"); !ok || v != "v0.14.0" {
+		t.Fatalf("unexpected version %s", v)
+	}
+
+	// built in a GOPATH
+	lines = []string{"/go/bin/gomobile: go1.17.8", "\tpath\tgolang.org/x/mobile/cmd/gomobile", "\tmod\tgolang.org/x/mobile\t(devel)\t"}
+	if v, ok := binaryModuleVersion(lines, "golang.org/x/mobile"); ok {
+		t.Fatalf("expected no version but got %s", v)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A moduleVersion is a node of the module graph. A main module has no version.
+type moduleVersion struct {
+	Path    string
+	Version Version
+	Main    bool
+}
+
+func (m moduleVersion) String() string {
+	if m.Main {
+		return m.Path
+	}
+	return m.Path + "@" + m.Version.String()
+}
+
+// A moduleGraph contains the requirements of each module version, as printed by go mod graph
+type moduleGraph map[moduleVersion][]moduleVersion
+
+// parseModuleGraph reads the output of go mod graph. Other lines, like the download progress of go, are ignored,
+// as well as the go and toolchain requirements of newer go versions.
+func parseModuleGraph(lines []string) (moduleGraph, error) {
+	graph := make(moduleGraph)
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || strings.HasPrefix(line, "go: ") {
+			continue
+		}
+		from, err := parseModuleVersion(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if strings.HasPrefix(fields[1], "go@") || strings.HasPrefix(fields[1], "toolchain@") {
+			continue
+		}
+		to, err := parseModuleVersion(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if to.Main {
+			return nil, fmt.Errorf("line %d: requirement without version '%s'", i+1, fields[1])
+		}
+		graph[from] = append(graph[from], to)
+	}
+	return graph, nil
+}
+
+// parseModuleVersion parses a node like golang.org/x/text@v0.3.2 or a main module like example.com/a
+func parseModuleVersion(str string) (moduleVersion, error) {
+	idx := strings.LastIndex(str, "@")
+	if idx < 0 {
+		return moduleVersion{Path: str, Main: true}, nil
+	}
+	v, err := parseVendoredVersion(str[idx+1:])
+	if err != nil {
+		return moduleVersion{}, fmt.Errorf("invalid module '%s': %v", str, err)
+	}
+	return moduleVersion{Path: str[:idx], Version: v}, nil
+}
+
+// add merges the requirements of the other graph into this graph
+func (g moduleGraph) add(other moduleGraph) {
+	for from, reqs := range other {
+		if _, ok := g[from]; !ok {
+			g[from] = reqs
+		}
+	}
+}
+
+// mainModules returns the main modules of the graph, sorted by path
+func (g moduleGraph) mainModules() []moduleVersion {
+	res := make([]moduleVersion, 0)
+	for m := range g {
+		if m.Main {
+			res = append(res, m)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+// versionsOf returns all required versions of the module. Local modules are replaced, so that all their versions
+// have the same requirements.
+func (g moduleGraph) versionsOf(path string) []moduleVersion {
+	res := make([]moduleVersion, 0)
+	for _, reqs := range g {
+		for _, m := range reqs {
+			if m.Path == path {
+				res = append(res, m)
+			}
+		}
+	}
+	return res
+}
+
+// buildList performs the minimal version selection of go: each module is selected by the highest version,
+// which is reachable from the roots. Requirements of versions which are not selected are still followed.
+func (g moduleGraph) buildList(roots ...moduleVersion) map[string]Version {
+	selected := make(map[string]Version)
+	visited := make(map[moduleVersion]bool)
+	queue := append([]moduleVersion{}, roots...)
+	for len(queue) > 0 {
+		m := queue[0]
+		queue = queue[1:]
+		if visited[m] {
+			continue
+		}
+		visited[m] = true
+		if !m.Main {
+			if v, ok := selected[m.Path]; !ok || m.Version.IsNewer(v) {
+				selected[m.Path] = m.Version
+			}
+		}
+		queue = append(queue, g[m]...)
+	}
+	return selected
+}
+
+// sortedModulePaths returns the module paths of a build list in a stable order
+func sortedModulePaths(buildList map[string]Version) []string {
+	res := make([]string, 0, len(buildList))
+	for path := range buildList {
+		res = append(res, path)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// A depConflict describes a module, whose selected version differs from the version a local module requires
+type depConflict struct {
+	// Module is the path of the required module
+	Module string
+	// Requested is the version, which the local module would select on its own
+	Requested Version
+	// RequestedBy is the path of the local module
+	RequestedBy string
+	// SelectedModule is the path of the selected module, which only differs from Module for another major
+	// version, like github.com/x/y/v2
+	SelectedModule string
+	// Selected is the version, which is used for all local modules
+	Selected Version
+}
+
+// Major returns true, if the requested and the selected version have a different major version
+func (c depConflict) Major() bool {
+	return c.Module != c.SelectedModule || c.Requested.Major != c.Selected.Major
+}
+
+func (c depConflict) String() string {
+	str := fmt.Sprintf("%s: %s requires %s, but %s is selected", c.Module, c.RequestedBy, c.Requested, c.Selected)
+	if c.Module != c.SelectedModule {
+		str = fmt.Sprintf("%s: %s requires %s, but %s %s is selected as well", c.Module, c.RequestedBy, c.Requested, c.SelectedModule, c.Selected)
+	}
+	if c.Major() {
+		str += " (major version conflict)"
+	}
+	return str
+}
+
+// majorSuffixRegex matches the major version suffix of a module path, like /v2 or .v3 for gopkg.in
+var majorSuffixRegex = regexp.MustCompile(`(/v[0-9]+|^gopkg\.in/.*(\.v[0-9]+))$`)
+
+// modulePathBase returns the module path without its major version suffix
+func modulePathBase(path string) string {
+	m := majorSuffixRegex.FindStringSubmatchIndex(path)
+	if m == nil {
+		return path
+	}
+	if m[4] >= 0 {
+		return path[:m[4]]
+	}
+	return path[:m[2]]
+}
+
+// dependencyConflicts compares the build list of each local module with the selection for all local modules.
+// A conflict is either a different version of the same module path or another major version of the same module,
+// which the local module does not require. The local modules themselves are never conflicting, because they
+// are always used as they are.
+func dependencyConflicts(selected map[string]Version, requested map[string]map[string]Version) []depConflict {
+	majors := make(map[string][]string)
+	for path := range selected {
+		base := modulePathBase(path)
+		majors[base] = append(majors[base], path)
+	}
+
+	res := make([]depConflict, 0)
+	for local, buildList := range requested {
+		for path, v := range buildList {
+			if _, ok := requested[path]; ok {
+				continue
+			}
+			if sel, ok := selected[path]; ok && sel.Compare(v) != 0 {
+				res = append(res, depConflict{Module: path, Requested: v, RequestedBy: local, SelectedModule: path, Selected: sel})
+			}
+			for _, other := range majors[modulePathBase(path)] {
+				if _, ok := buildList[other]; ok {
+					continue
+				}
+				if _, ok := requested[other]; ok {
+					continue
+				}
+				res = append(res, depConflict{Module: path, Requested: v, RequestedBy: local, SelectedModule: other, Selected: selected[other]})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		if a.Module != b.Module {
+			return a.Module < b.Module
+		}
+		if a.RequestedBy != b.RequestedBy {
+			return a.RequestedBy < b.RequestedBy
+		}
+		return a.SelectedModule < b.SelectedModule
+	})
+	return res
+}
+
+// reportConflicts logs each conflict and fails for -strict-deps
+func (g *GoUp) reportConflicts(conflicts []depConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(conflicts))
+	for _, c := range conflicts {
+		logger.Warn(Fields{"msg": "dependency conflict", "module": c.Module, "required_by": c.RequestedBy,
+			"requires": c.Requested.String(), "selected": c.SelectedModule + "@" + c.Selected.String(), "major": c.Major()})
+		lines = append(lines, c.String())
+	}
+	if g.args.StrictDeps {
+		return fmt.Errorf("the local modules require conflicting dependency versions:\n%s", strings.Join(lines, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// modGraphOutput is the graph of the mvs example of Russ Cox, A is the main module. C 1.2 requires D 1.4,
+// but C 1.2 is not selected. Nevertheless D 1.4 is selected, even though no selected module requires it.
+const modGraphOutput = `go: downloading example.com/b v1.2.0
+example.com/a example.com/b@v1.2.0
+example.com/a example.com/c@v1.2.0
+example.com/a go@1.21
+example.com/b@v1.2.0 example.com/d@v1.3.0
+example.com/c@v1.2.0 example.com/d@v1.4.0
+example.com/b@v1.2.0 example.com/c@v1.3.0
+example.com/c@v1.3.0 example.com/f@v1.1.0
+example.com/d@v1.3.0 example.com/e@v1.2.0
+example.com/d@v1.4.0 example.com/e@v1.2.0
+example.com/e@v1.2.0 toolchain@go1.21.1
+`
+
+func TestParseModuleGraph(t *testing.T) {
+	graph, err := parseModuleGraph(strings.Split(modGraphOutput, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := moduleVersion{Path: "example.com/a", Main: true}
+	b := moduleVersion{Path: "example.com/b", Version: Version{Major: 1, Minor: 2}}
+	c := moduleVersion{Path: "example.com/c", Version: Version{Major: 1, Minor: 2}}
+	if !reflect.DeepEqual(graph[a], []moduleVersion{b, c}) {
+		t.Fatalf("unexpected requirements %v", graph[a])
+	}
+	if len(graph) != 6 {
+		t.Fatalf("expected 6 modules but got %v", graph)
+	}
+	if !reflect.DeepEqual(graph.mainModules(), []moduleVersion{a}) {
+		t.Fatalf("unexpected main modules %v", graph.mainModules())
+	}
+	if a.String() != "example.com/a" || b.String() != "example.com/b@v1.2.0" {
+		t.Fatalf("unexpected strings %s %s", a, b)
+	}
+}
+
+func TestParseModuleGraphErrors(t *testing.T) {
+	cases := map[string]string{
+		"example.com/a example.com/b@1.2.0":       "line 1: invalid module 'example.com/b@1.2.0'",
+		"\nexample.com/a@vx example.com/b@v1.2.0": "line 2: invalid module 'example.com/a@vx'",
+		"example.com/a example.com/b":             "line 1: requirement without version 'example.com/b'",
+	}
+	for text, msg := range cases {
+		_, err := parseModuleGraph(strings.Split(text, "\n"))
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%q: expected '%s' but got %v", text, msg, err)
+		}
+	}
+}
+
+func TestBuildList(t *testing.T) {
+	graph, err := parseModuleGraph(strings.Split(modGraphOutput, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]Version{
+		"example.com/b": {Major: 1, Minor: 2},
+		"example.com/c": {Major: 1, Minor: 3},
+		"example.com/d": {Major: 1, Minor: 4},
+		"example.com/e": {Major: 1, Minor: 2},
+		"example.com/f": {Major: 1, Minor: 1},
+	}
+	if list := graph.buildList(graph.mainModules()...); !reflect.DeepEqual(list, expected) {
+		t.Fatalf("expected %v but got %v", expected, list)
+	}
+
+	// the requirements of a module, independent of the main module
+	b := graph.versionsOf("example.com/b")
+	expected = map[string]Version{
+		"example.com/b": {Major: 1, Minor: 2},
+		"example.com/c": {Major: 1, Minor: 3},
+		"example.com/d": {Major: 1, Minor: 3},
+		"example.com/e": {Major: 1, Minor: 2},
+		"example.com/f": {Major: 1, Minor: 1},
+	}
+	if list := graph.buildList(b...); !reflect.DeepEqual(list, expected) {
+		t.Fatalf("expected %v but got %v", expected, list)
+	}
+}
+
+func TestBuildListMerged(t *testing.T) {
+	// two local modules, x vendors sub at v1.0.0 and y requires dep v1.1.0, which requires sub v1.2.0
+	x, err := parseModuleGraph([]string{
+		"example.com/x example.org/dep@v1.0.0",
+		"example.com/x example.org/sub@v1.0.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := parseModuleGraph([]string{
+		"example.com/y example.org/dep@v1.1.0",
+		"example.org/dep@v1.1.0 example.org/sub@v1.2.0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	graph := make(moduleGraph)
+	graph.add(x)
+	graph.add(y)
+	expected := map[string]Version{
+		"example.org/dep": {Major: 1, Minor: 1},
+		"example.org/sub": {Major: 1, Minor: 2},
+	}
+	if list := graph.buildList(graph.mainModules()...); !reflect.DeepEqual(list, expected) {
+		t.Fatalf("expected %v but got %v", expected, list)
+	}
+}
+
+func TestModulePathBase(t *testing.T) {
+	cases := map[string]string{
+		"github.com/x/y":      "github.com/x/y",
+		"github.com/x/y/v2":   "github.com/x/y",
+		"github.com/x/y/v10":  "github.com/x/y",
+		"github.com/x/v2/y":   "github.com/x/v2/y",
+		"gopkg.in/yaml.v3":    "gopkg.in/yaml",
+		"gopkg.in/x/yaml.v2":  "gopkg.in/x/yaml",
+		"example.com/yaml.v3": "example.com/yaml.v3",
+	}
+	for path, expected := range cases {
+		if base := modulePathBase(path); base != expected {
+			t.Fatalf("%s: expected %s but got %s", path, expected, base)
+		}
+	}
+}
+
+func TestDependencyConflicts(t *testing.T) {
+	v := func(str string) Version {
+		res, err := ParseSemanticVersion(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	selected := map[string]Version{
+		"example.com/b":      v("v0.0.0-00010101000000-000000000000"),
+		"example.org/dep":    v("v1.1.0"),
+		"example.org/dep/v2": v("v2.0.0"),
+		"example.org/sub":    v("v1.2.0"),
+		"example.org/docker": v("v20.10.8+incompatible"),
+		"example.org/same":   v("v1.0.0"),
+	}
+	requested := map[string]map[string]Version{
+		"example.com/a": {
+			"example.com/b":      v("v0.0.0-00010101000000-000000000000"),
+			"example.org/dep":    v("v1.0.0"),
+			"example.org/sub":    v("v1.2.0"),
+			"example.org/docker": v("v1.13.1"),
+			"example.org/same":   v("v1.0.0"),
+		},
+		"example.com/b": {
+			"example.org/dep":    v("v1.1.0"),
+			"example.org/dep/v2": v("v2.0.0"),
+			"example.org/sub":    v("v1.2.0"),
+			"example.org/docker": v("v20.10.8+incompatible"),
+			"example.org/same":   v("v1.0.0"),
+		},
+	}
+	conflicts := dependencyConflicts(selected, requested)
+	expected := []string{
+		"example.org/dep: example.com/a requires v1.0.0, but v1.1.0 is selected",
+		"example.org/dep: example.com/a requires v1.0.0, but example.org/dep/v2 v2.0.0 is selected as well (major version conflict)",
+		"example.org/docker: example.com/a requires v1.13.1, but v20.10.8+incompatible is selected (major version conflict)",
+	}
+	if len(conflicts) != len(expected) {
+		t.Fatalf("expected %d conflicts but got %v", len(expected), conflicts)
+	}
+	for i, c := range conflicts {
+		if c.String() != expected[i] {
+			t.Fatalf("expected\n%s\nbut got\n%s", expected[i], c.String())
+		}
+	}
+	if conflicts[0].Major() || !conflicts[1].Major() || !conflicts[2].Major() {
+		t.Fatalf("unexpected major conflicts %v", conflicts)
+	}
+}
+
+func TestReportConflicts(t *testing.T) {
+	conflicts := []depConflict{{Module: "example.org/dep", Requested: Version{Major: 1}, RequestedBy: "example.com/a",
+		SelectedModule: "example.org/dep", Selected: Version{Major: 1, Minor: 1}}}
+	g := &GoUp{args: &Args{}}
+	if err := g.reportConflicts(conflicts); err != nil {
+		t.Fatal(err)
+	}
+	g.args.StrictDeps = true
+	if err := g.reportConflicts(nil); err != nil {
+		t.Fatal(err)
+	}
+	err := g.reportConflicts(conflicts)
+	if err == nil || !strings.Contains(err.Error(), "example.org/dep: example.com/a requires v1.0.0, but v1.1.0 is selected") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
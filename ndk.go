@@ -0,0 +1,173 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// androidArchs maps the gomobile architecture names to their android abi names
+var androidArchs = map[string]string{
+	"arm":   "armeabi-v7a",
+	"arm64": "arm64-v8a",
+	"386":   "x86",
+	"amd64": "x86_64",
+}
+
+// androidArch returns the gomobile architecture of either a gomobile architecture or android abi name
+func androidArch(abi string) (string, bool) {
+	abi = strings.TrimSpace(abi)
+	if _, ok := androidArchs[abi]; ok {
+		return abi, true
+	}
+	for arch, name := range androidArchs {
+		if name == abi {
+			return arch, true
+		}
+	}
+	return "", false
+}
+
+// defaultAndroidAPI is the api level used by gomobile, if -androidapi is not given
+const defaultAndroidAPI = 16
+
+// api returns the effective api level, which is the default of gomobile, if not declared
+func (a *Android) api() int {
+	if a.Api > 0 {
+		return a.Api
+	}
+	return defaultAndroidAPI
+}
+
+// archs returns the effective gomobile architectures, which are all of them, if not declared
+func (a *Android) archs() []string {
+	archs := make([]string, 0, len(androidArchs))
+	if len(a.Abis) == 0 {
+		for arch := range androidArchs {
+			archs = append(archs, arch)
+		}
+		sort.Strings(archs)
+		return archs
+	}
+	for _, abi := range a.Abis {
+		arch, _ := androidArch(abi) // already validated
+		archs = append(archs, arch)
+	}
+	return archs
+}
+
+// androidTarget returns the gomobile -target value, e.g. android or android/arm64,android/amd64
+func (a *Android) androidTarget() string {
+	if len(a.Abis) == 0 {
+		return "android"
+	}
+	targets := make([]string, 0, len(a.Abis))
+	for _, arch := range a.archs() {
+		targets = append(targets, "android/"+arch)
+	}
+	return strings.Join(targets, ",")
+}
+
+// validate checks the api level and abi names without the ndk
+func (a *Android) validate(add func(field string, format string, args ...interface{})) {
+	if a.Api < 0 {
+		add("build.gomobile.android.api", "must be a positive api level, but is %d", a.Api)
+	}
+	unique := make(map[string]bool)
+	for i, abi := range a.Abis {
+		arch, ok := androidArch(abi)
+		if !ok {
+			names := make([]string, 0)
+			for arch, name := range androidArchs {
+				names = append(names, arch+" ("+name+")")
+			}
+			sort.Strings(names)
+			add(fmt.Sprintf("build.gomobile.android.abis[%d]", i), "unknown abi '%s', expected one of %s", abi, strings.Join(names, ", "))
+			continue
+		}
+		if unique[arch] {
+			add(fmt.Sprintf("build.gomobile.android.abis[%d]", i), "duplicate abi '%s'", abi)
+		}
+		unique[arch] = true
+	}
+}
+
+// ndkMeta describes the api levels and abis supported by an installed ndk, as declared in its meta folder
+type ndkMeta struct {
+	// MinAPI is the lowest supported api level
+	MinAPI int `json:"min"`
+	// MaxAPI is the highest supported api level
+	MaxAPI int `json:"max"`
+	// Abis contains the supported android abi names, e.g. arm64-v8a
+	Abis map[string]interface{} `json:"-"`
+}
+
+// loadNdkMeta reads meta/platforms.json and meta/abis.json of the ndk. These files are available since r18.
+func loadNdkMeta(ndkHome Path) (*ndkMeta, error) {
+	meta := &ndkMeta{}
+	data, err := ioutil.ReadFile(ndkHome.Child("meta").Child("platforms.json").String())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("invalid platforms.json: %v", err)
+	}
+
+	data, err = ioutil.ReadFile(ndkHome.Child("meta").Child("abis.json").String())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &meta.Abis); err != nil {
+		return nil, fmt.Errorf("invalid abis.json: %v", err)
+	}
+	return meta, nil
+}
+
+// validateNdk checks the android api level and abis against the installed ndk. Older ndks without meta
+// information are not checked.
+func (g *GoUp) validateNdk() error {
+	if !g.hasAndroidBuild() {
+		return nil
+	}
+	android := g.config.Build.Gomobile.Android
+	ndkHome := Path(g.env["ANDROID_NDK_HOME"])
+	ndkVersion := g.toolchainVersions().Ndk
+	meta, err := loadNdkMeta(ndkHome)
+	if err != nil {
+		logger.Debug(Fields{"msg": "cannot validate android target against ndk", "ndk": ndkHome, "err": err.Error()})
+		return nil
+	}
+
+	if api := android.api(); api < meta.MinAPI || api > meta.MaxAPI {
+		if android.Api == 0 {
+			return fmt.Errorf("the default android api level %d of gomobile is not supported by ndk %s, which supports %d to %d. Declare build.gomobile.android.api", api, ndkVersion, meta.MinAPI, meta.MaxAPI)
+		}
+		return fmt.Errorf("android api level %d is not supported by ndk %s, which supports %d to %d", api, ndkVersion, meta.MinAPI, meta.MaxAPI)
+	}
+
+	for _, arch := range android.archs() {
+		if _, ok := meta.Abis[androidArchs[arch]]; !ok {
+			if len(android.Abis) == 0 {
+				return fmt.Errorf("android abi %s (%s) of the default targets is not supported by ndk %s. Declare build.gomobile.android.abis", arch, androidArchs[arch], ndkVersion)
+			}
+			return fmt.Errorf("android abi %s (%s) is not supported by ndk %s", arch, androidArchs[arch], ndkVersion)
+		}
+	}
+	return nil
+}
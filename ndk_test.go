@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ndkFixture returns the absolute path of an ndk in testdata, whose meta folder describes its api levels and abis
+func ndkFixture(t *testing.T, name string) Path {
+	dir, err := filepath.Abs(filepath.Join("testdata", "ndk", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Path(dir)
+}
+
+func TestAndroidTarget(t *testing.T) {
+	cases := []struct {
+		abis     []string
+		expected string
+	}{
+		{nil, "android"},
+		{[]string{"arm64"}, "android/arm64"},
+		{[]string{"arm64-v8a", "x86_64"}, "android/arm64,android/amd64"},
+		{[]string{" armeabi-v7a", "386"}, "android/arm,android/386"},
+	}
+	for _, c := range cases {
+		if target := (&Android{Abis: c.abis}).androidTarget(); target != c.expected {
+			t.Fatalf("%v: expected %s but got %s", c.abis, c.expected, target)
+		}
+	}
+
+	if archs := (&Android{}).archs(); !reflect.DeepEqual(archs, []string{"386", "amd64", "arm", "arm64"}) {
+		t.Fatalf("unexpected default archs %v", archs)
+	}
+	if api := (&Android{}).api(); api != defaultAndroidAPI {
+		t.Fatalf("unexpected default api %d", api)
+	}
+}
+
+func TestAndroidValidate(t *testing.T) {
+	cases := []struct {
+		android  Android
+		expected []string
+	}{
+		{Android{Api: 21, Abis: []string{"arm64", "x86_64"}}, nil},
+		{Android{Api: -1}, []string{"build.gomobile.android.api: must be a positive api level, but is -1"}},
+		{Android{Abis: []string{"mips"}}, []string{"build.gomobile.android.abis[0]: unknown abi 'mips', expected one of 386 (x86), amd64 (x86_64), arm (armeabi-v7a), arm64 (arm64-v8a)"}},
+		{Android{Abis: []string{"arm64", "arm64-v8a"}}, []string{"build.gomobile.android.abis[1]: duplicate abi 'arm64-v8a'"}},
+	}
+	for _, c := range cases {
+		var problems []string
+		c.android.validate(func(field string, format string, args ...interface{}) {
+			problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+		})
+		if !reflect.DeepEqual(problems, c.expected) {
+			t.Fatalf("%+v: expected %v but got %v", c.android, c.expected, problems)
+		}
+	}
+}
+
+func TestLoadNdkMeta(t *testing.T) {
+	meta, err := loadNdkMeta(ndkFixture(t, "r19c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.MinAPI != 16 || meta.MaxAPI != 28 || len(meta.Abis) != 4 || meta.Abis["arm64-v8a"] == nil {
+		t.Fatalf("unexpected meta %+v", meta)
+	}
+
+	_, err = loadNdkMeta(ndkFixture(t, "invalid"))
+	if err == nil || !strings.Contains(err.Error(), "invalid platforms.json") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err = loadNdkMeta(ndkFixture(t, "missing")); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestValidateNdk(t *testing.T) {
+	validate := func(ndk string, android *Android) error {
+		g := newTestGoUp(nil)
+		g.args.Targets = []string{"all"}
+		g.env["ANDROID_NDK_HOME"] = ndkFixture(t, ndk).String()
+		g.config.Build = &Build{Gomobile: &BuildGomobile{Android: android, Toolchain: BuildGomobileToolchain{Ndk: ndk}}}
+		return g.validateNdk()
+	}
+
+	cases := []struct {
+		ndk      string
+		android  *Android
+		expected string
+	}{
+		{"r19c", &Android{}, ""},
+		{"r19c", &Android{Api: 28, Abis: []string{"x86"}}, ""},
+		{"r19c", &Android{Api: 29}, "android api level 29 is not supported by ndk r19c, which supports 16 to 28"},
+		{"r25b", &Android{}, "the default android api level 16 of gomobile is not supported by ndk r25b, which supports 19 to 33. Declare build.gomobile.android.api"},
+		{"r25b", &Android{Api: 21}, "android abi 386 (x86) of the default targets is not supported by ndk r25b. Declare build.gomobile.android.abis"},
+		{"r25b", &Android{Api: 21, Abis: []string{"arm64", "x86"}}, "android abi 386 (x86) is not supported by ndk r25b"},
+		{"r25b", &Android{Api: 21, Abis: []string{"arm64", "amd64"}}, ""},
+		{"r25b", &Android{Disabled: true}, ""},
+		// ndks before r18 have no meta information
+		{"missing", &Android{Api: 99}, ""},
+	}
+	for _, c := range cases {
+		err := validate(c.ndk, c.android)
+		if c.expected == "" && err != nil || c.expected != "" && (err == nil || err.Error() != c.expected) {
+			t.Fatalf("%s %+v: expected '%s' but got %v", c.ndk, c.android, c.expected, err)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ApplyProfile overlays the build section with the named profile. A value of the profile replaces the value of the
+// build section, if it is not empty or explicitly declared (e.g. disabled: false). Lists are replaced, not merged.
+func (c *GoUpConfiguration) ApplyProfile(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for k := range c.Profiles {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown profile '%s', available profiles are: %s", name, strings.Join(names, ", "))
+	}
+	if profile == nil {
+		return nil
+	}
+	if c.Build == nil {
+		c.Build = &Build{}
+	}
+	overlay(reflect.ValueOf(c.Build).Elem(), reflect.ValueOf(profile).Elem(), "profiles."+name, func(field string) bool {
+		_, declared := c.positions[field]
+		return declared
+	}, false)
+	return nil
+}
+
+// overlay copies the non-zero or declared fields of src into dst, recursing into structs and maps of structs.
+// Lists are replaced, unless appendLists is set and the field is not tagged with merge:"replace". In that case
+// the missing elements of src are appended to dst, or all of them for merge:"append".
+func overlay(dst reflect.Value, src reflect.Value, field string, declared func(field string) bool, appendLists bool) {
+	for i := 0; i < src.NumField(); i++ {
+		f := src.Type().Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := joinField(field, yamlName(f))
+		s, d := src.Field(i), dst.Field(i)
+
+		switch {
+		case isStructPtr(s.Type()):
+			if s.IsNil() {
+				continue
+			}
+			if d.IsNil() {
+				d.Set(reflect.New(s.Type().Elem()))
+			}
+			overlay(d.Elem(), s.Elem(), name, declared, appendLists)
+		case s.Kind() == reflect.Struct:
+			overlay(d, s, name, declared, appendLists)
+		case s.Kind() == reflect.Map:
+			if s.IsNil() {
+				continue
+			}
+			merged := reflect.MakeMap(s.Type())
+			if !d.IsNil() {
+				for _, key := range d.MapKeys() {
+					merged.SetMapIndex(key, d.MapIndex(key))
+				}
+			}
+			for _, key := range s.MapKeys() {
+				sv, dv := s.MapIndex(key), merged.MapIndex(key)
+				if isStructPtr(sv.Type()) && dv.IsValid() && !dv.IsNil() && !sv.IsNil() {
+					tmp := reflect.New(dv.Type().Elem())
+					tmp.Elem().Set(dv.Elem())
+					overlay(tmp.Elem(), sv.Elem(), joinField(name, key.String()), declared, appendLists)
+					sv = tmp
+				}
+				merged.SetMapIndex(key, sv)
+			}
+			d.Set(merged)
+		case s.Kind() == reflect.Slice && appendLists && f.Tag.Get("merge") == "append":
+			d.Set(reflect.AppendSlice(d, s))
+		case s.Kind() == reflect.Slice && appendLists && f.Tag.Get("merge") != "replace":
+			for j := 0; j < s.Len(); j++ {
+				if !containsValue(d, s.Index(j)) {
+					d.Set(reflect.Append(d, s.Index(j)))
+				}
+			}
+		default:
+			if !isZero(s) || declared(name) {
+				d.Set(s)
+			}
+		}
+	}
+}
+
+// isStructPtr checks if t is a pointer to a struct
+func isStructPtr(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct
+}
+
+// containsValue checks if the slice contains a deeply equal value
+func containsValue(slice reflect.Value, v reflect.Value) bool {
+	for i := 0; i < slice.Len(); i++ {
+		if reflect.DeepEqual(slice.Index(i).Interface(), v.Interface()) {
+			return true
+		}
+	}
+	return false
+}
+
+// isZero is like reflect.Value.IsZero, which is not available in go 1.12
+func isZero(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	default:
+		return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "goup.yaml")
+	err = ioutil.WriteFile(file, []byte(`name: app
+build:
+  gomobile:
+    modules: [./x]
+    export: [x]
+    android:
+      out: ./lib.aar
+      abis: [arm, arm64]
+      trimpath: true
+    ios:
+      ldflags: -X a=b
+profiles:
+  release:
+    gomobile:
+      android:
+        abis: [arm64]
+        trimpath: false
+      ios:
+        ldflags: -s -w
+`), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(file)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyProfile("debug"); err == nil {
+		t.Fatal("expected unknown profile error")
+	}
+	if err := cfg.ApplyProfile("release"); err != nil {
+		t.Fatal(err)
+	}
+
+	g := cfg.Build.Gomobile
+	if !reflect.DeepEqual(g.Android.Abis, []string{"arm64"}) {
+		t.Fatal("unexpected abis", g.Android.Abis)
+	}
+	if g.Android.Trimpath {
+		t.Fatal("declared trimpath: false must override")
+	}
+	if g.Android.Out != "./lib.aar" || g.Ios.Ldflags != "-s -w" {
+		t.Fatal("unexpected overlay", g.Android.Out, g.Ios.Ldflags)
+	}
+	if !reflect.DeepEqual(g.Modules, []ModuleSpecifier{"./x"}) {
+		t.Fatal("unexpected modules", g.Modules)
+	}
+}
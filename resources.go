@@ -70,13 +70,19 @@ func (r *Resources) Get(name string, version string) (Resource, error) {
 
 // Load parses a local xml file and replaces the contents of resources
 func (r *Resources) Load(fname Path) error {
-	tmp := &resources{}
-	*r = make([]Resource, 0)
 	data, err := ioutil.ReadFile(fname.String())
 	if err != nil {
+		*r = make([]Resource, 0)
 		return fmt.Errorf("unable to read xml file: %v", err)
 	}
-	err = xml.Unmarshal(data, tmp)
+	return r.Parse(data)
+}
+
+// Parse reads the xml data and replaces the contents of resources
+func (r *Resources) Parse(data []byte) error {
+	tmp := &resources{}
+	*r = make([]Resource, 0)
+	err := xml.Unmarshal(data, tmp)
 	if err != nil {
 		return fmt.Errorf("unable to parse xml: %v", err)
 	}
@@ -0,0 +1,171 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// the descriptions need to be compiled, before the schema can be generated
+//go:generate go test -run ^TestSchemaDocs$ -update
+//go:generate go test -run ^TestSchema$ -update
+
+// schemaEnums maps the fields of the toolchain to the name of their resources
+var schemaEnums = map[string]string{
+	"BuildGomobileToolchain.Go":       "go",
+	"BuildGomobileToolchain.Ndk":      "ndk",
+	"BuildGomobileToolchain.Sdk":      "sdk",
+	"BuildGomobileToolchain.Jdk":      "jdk",
+	"BuildGomobileToolchain.Gomobile": "gomobile",
+}
+
+// schemaFixedEnums contains the allowed values of fields, which do not depend on the resources
+var schemaFixedEnums = map[string][]interface{}{
+	"BuildGomobile.Mode": {modeGopath, modeModules, nil},
+}
+
+// GenerateSchema creates a JSON Schema (draft-07) for goup.yaml from the configuration structs. Descriptions are
+// taken from the doc comments of the structs and the toolchain versions are restricted to the given resources.
+func GenerateSchema(resources *Resources) map[string]interface{} {
+	schema := schemaOf(reflect.TypeOf(GoUpConfiguration{}), "", resources)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = goup + ".yaml"
+	return schema
+}
+
+// WriteSchema writes the indented JSON Schema into w
+func WriteSchema(w io.Writer, resources *Resources) error {
+	data, err := json.MarshalIndent(GenerateSchema(resources), "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// PrintSchema writes the JSON Schema of goup.yaml into w, using the cached resource list for the toolchain versions
+func PrintSchema(args *Args, w io.Writer) error {
+	flags := args.FlagSet("schema")
+	args.projectFlags(flags)
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(args.HomeDir.String(), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	g := &GoUp{args: args}
+	resources, err := g.loadResources()
+	if err != nil {
+		return err
+	}
+	return WriteSchema(w, resources)
+}
+
+// schemaOf returns the schema of the given type, field is the qualified name like Ios.Out, if t belongs to a field
+func schemaOf(t reflect.Type, field string, resources *Resources) map[string]interface{} {
+	schema := make(map[string]interface{})
+	if doc, ok := configDocs[field]; ok {
+		schema["description"] = doc
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		for k, v := range schemaOf(t.Elem(), field, resources) {
+			schema[k] = v
+		}
+	case reflect.Struct:
+		if doc, ok := configDocs[t.Name()]; ok && len(field) == 0 {
+			schema["description"] = doc
+		}
+		if t == reflect.TypeOf(Secret{}) {
+			// a plain name or the full declaration, see Secret.UnmarshalYAML
+			schema["oneOf"] = []interface{}{map[string]interface{}{"type": "string"}, structSchema(t, resources)}
+			break
+		}
+		for k, v := range structSchema(t, resources) {
+			schema[k] = v
+		}
+	case reflect.Slice:
+		schema["type"] = []string{"array", "null"}
+		schema["items"] = schemaOf(t.Elem(), "", resources)
+	case reflect.Map:
+		schema["type"] = []string{"object", "null"}
+		schema["additionalProperties"] = schemaOf(t.Elem(), "", resources)
+	case reflect.Bool:
+		schema["type"] = []string{"boolean", "null"}
+	case reflect.Int, reflect.Int64:
+		schema["type"] = []string{"integer", "null"}
+	case reflect.String:
+		// yaml has no quotes, so versions like 4333796 are numbers
+		schema["type"] = []string{"string", "number", "null"}
+		if name, ok := schemaEnums[field]; ok && resources != nil {
+			schema["enum"] = resourceVersions(resources, name)
+		}
+		if enum, ok := schemaFixedEnums[field]; ok {
+			schema["enum"] = enum
+		}
+	}
+	return schema
+}
+
+// structSchema returns an object schema with the properties of all exported fields
+func structSchema(t reflect.Type, resources *Resources) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		properties[yamlName(f)] = schemaOf(f.Type, t.Name()+"."+f.Name, resources)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+}
+
+// resourceVersions returns the sorted and distinct versions of the named resource, including their numeric
+// representation and null
+func resourceVersions(resources *Resources, name string) []interface{} {
+	versions := make([]string, 0)
+	unique := make(map[string]bool)
+	for _, res := range *resources {
+		if res.Name == name && !unique[res.Version] {
+			unique[res.Version] = true
+			versions = append(versions, res.Version)
+		}
+	}
+	sort.Strings(versions)
+
+	enum := make([]interface{}, 0)
+	for _, v := range versions {
+		enum = append(enum, v)
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			enum = append(enum, n)
+		} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+			// e.g. 1.14 would be a float in yaml
+			enum = append(enum, f)
+		}
+	}
+	return append(enum, nil)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerates schema_docs.go and goup.schema.json")
+
+// TestSchemaDocs ensures that the descriptions of the schema match the doc comments of goupyaml.go
+func TestSchemaDocs(t *testing.T) {
+	docs, err := parseConfigDocs("goupyaml.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb := &bytes.Buffer{}
+	sb.WriteString("// Code generated by go test -run ^TestSchemaDocs$ -update; DO NOT EDIT.\n\n")
+	sb.WriteString("package main\n\n")
+	sb.WriteString("// configDocs contains the doc comments of the configuration structs, to describe the JSON Schema\n")
+	sb.WriteString("var configDocs = map[string]string{\n")
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s: %s,\n", strconv.Quote(k), strconv.Quote(docs[k])))
+	}
+	sb.WriteString("}\n")
+	src, err := format.Source(sb.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGenerated(t, "schema_docs.go", src)
+}
+
+// TestSchema ensures that the published goup.schema.json matches the configuration structs
+func TestSchema(t *testing.T) {
+	resources := &Resources{}
+	if err := resources.Load(Path(CWD()).Child("resources.xml")); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := WriteSchema(buf, resources); err != nil {
+		t.Fatal(err)
+	}
+	assertGenerated(t, goup+".schema.json", buf.Bytes())
+}
+
+// assertGenerated compares the file with the expected content or overwrites it, if -update is set
+func assertGenerated(t *testing.T, fname string, expected []byte) {
+	if *update {
+		if err := ioutil.WriteFile(fname, expected, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	actual, err := ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("%s is outdated, run go generate", fname)
+	}
+}
+
+// parseConfigDocs returns the normalized doc comments of all structs and their fields, e.g. Ios and Ios.Out
+func parseConfigDocs(fname string) (map[string]string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), fname, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]string)
+	normalize := func(group *ast.CommentGroup) string {
+		return strings.Join(strings.Fields(group.Text()), " ")
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			typeSpec := spec.(*ast.TypeSpec)
+			structType, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			if gen.Doc != nil {
+				docs[typeSpec.Name.Name] = normalize(gen.Doc)
+			}
+			for _, field := range structType.Fields.List {
+				if field.Doc == nil {
+					continue
+				}
+				for _, name := range field.Names {
+					if ast.IsExported(name.Name) {
+						docs[typeSpec.Name.Name+"."+name.Name] = normalize(field.Doc)
+					}
+				}
+			}
+		}
+	}
+	return docs, nil
+}
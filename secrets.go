@@ -0,0 +1,165 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// loadSecrets reads the env files and secrets and configures the redactor with all values and patterns, before
+// anything gets logged. The values are kept in secretEnv and are never part of the artifact cache.
+func (g *GoUp) loadSecrets() error {
+	g.secretEnv = make(map[string]string)
+	for _, expr := range g.config.Redact {
+		err := redactor.AddPattern(expr)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range g.config.Env_files {
+		expanded, err := expandVars(string(file), g.lookupVar)
+		if err != nil {
+			return fmt.Errorf("invalid env file %s: %v", file, err)
+		}
+		resolved := Path(expanded).Resolve(g.args.BaseDir)
+		data, err := ioutil.ReadFile(resolved.String())
+		if err != nil {
+			return fmt.Errorf("failed to read env file: %v", err)
+		}
+		vars, err := ParseDotEnv(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse env file %s: %v", resolved, err)
+		}
+		for k, v := range vars {
+			redactor.AddSecret(v)
+			g.secretEnv[k] = v
+		}
+	}
+
+	for _, secret := range g.config.Secrets {
+		if IsEmpty(secret.Name) {
+			return fmt.Errorf("a secret requires a name")
+		}
+
+		// just mask an existing variable
+		if len(secret.File) == 0 && len(secret.Env) == 0 {
+			if val, ok := g.config.Variables[secret.Name]; ok {
+				redactor.AddSecret(val)
+			}
+			redactor.AddSecret(os.Getenv(secret.Name))
+			continue
+		}
+
+		file, err := expandVars(string(secret.File), g.lookupVar)
+		if err != nil {
+			return fmt.Errorf("invalid file of secret %s: %v", secret.Name, err)
+		}
+		secret.File = Path(file)
+		val, ok, err := secret.read(g.args.BaseDir)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if secret.Optional {
+				logger.Debug(Fields{"secret": secret.Name, "msg": "optional secret is not available"})
+				continue
+			}
+			return fmt.Errorf("secret %s is not available from file '%s' or environment variable '%s'", secret.Name, secret.File, secret.Env)
+		}
+		redactor.AddSecret(val)
+		g.secretEnv[secret.Name] = val
+	}
+	return nil
+}
+
+// read returns the value of the secret, preferring the file over the environment variable. Errors never contain
+// the value.
+func (s Secret) read(baseDir Path) (string, bool, error) {
+	if len(s.File) > 0 {
+		file := s.File.Resolve(baseDir)
+		data, err := ioutil.ReadFile(file.String())
+		if err == nil {
+			return strings.TrimSpace(string(data)), true, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", false, fmt.Errorf("failed to read secret %s: %v", s.Name, err)
+		}
+	}
+
+	if len(s.Env) > 0 {
+		if val, ok := os.LookupEnv(s.Env); ok {
+			return val, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// ParseDotEnv parses the contents of a dotenv file. Each line contains a KEY=VALUE pair, optionally prefixed by
+// export. Empty lines and lines starting with # are ignored. Values may be single quoted (taken literally) or
+// double quoted (supporting escapes like \n). Unquoted values end at a # comment.
+func ParseDotEnv(text string) (map[string]string, error) {
+	res := make(map[string]string)
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		idx := strings.Index(line, "=")
+		if idx < 1 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+		key := strings.TrimSpace(line[:idx])
+		val := strings.TrimSpace(line[idx+1:])
+
+		switch {
+		case strings.HasPrefix(val, "'"):
+			end := strings.Index(val[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated single quote", i+1)
+			}
+			val = val[1 : end+1]
+		case strings.HasPrefix(val, `"`):
+			// strconv.QuotedPrefix requires go 1.17, so the closing quote is searched by hand
+			end := 1
+			for end < len(val) && val[end] != '"' {
+				if val[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(val) {
+				return nil, fmt.Errorf("line %d: unterminated double quote", i+1)
+			}
+			unquoted, err := strconv.Unquote(val[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid double quoted value", i+1)
+			}
+			val = unquoted
+		default:
+			if idx := strings.Index(val, " #"); idx >= 0 {
+				val = strings.TrimSpace(val[:idx])
+			}
+		}
+		res[key] = val
+	}
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	text := `
+# comment
+A=1
+export B = two words
+C='lit\n # eral'
+D="line\nbreak"
+E=value # comment
+F=a=b=c
+G=
+H="say \"hi\"" # comment
+`
+	vars, err := ParseDotEnv(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"A": "1", "B": "two words", "C": `lit\n # eral`, "D": "line\nbreak", "E": "value", "F": "a=b=c", "G": "", "H": `say "hi"`}
+	if len(vars) != len(expected) {
+		t.Fatal("expected", expected, "but got", vars)
+	}
+	for k, v := range expected {
+		if vars[k] != v {
+			t.Fatalf("%s: expected '%s' but got '%s'", k, v, vars[k])
+		}
+	}
+
+	for _, invalid := range []string{"novalue", "=1", "A='open", `A="open`, `A="open\"`, `A="\q"`} {
+		if _, err := ParseDotEnv(invalid); err == nil {
+			t.Fatal("expected error for", invalid)
+		}
+	}
+}
+
+func TestUnmarshalSecrets(t *testing.T) {
+	cfg := &GoUpConfiguration{}
+	err := yaml.Unmarshal([]byte(`
+secrets:
+  - GITLAB_TOKEN
+  - name: NEXUS_PASSWORD
+    file: ./secrets/nexus
+    env: CI_NEXUS_PASSWORD
+    optional: true
+`), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Secrets) != 2 || cfg.Secrets[0].Name != "GITLAB_TOKEN" || cfg.Secrets[1].Env != "CI_NEXUS_PASSWORD" || !cfg.Secrets[1].Optional {
+		t.Fatal("unexpected secrets", cfg.Secrets)
+	}
+}
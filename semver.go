@@ -0,0 +1,181 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A Version represents a semver version, see https://semver.org. Go modules additionally use pseudo versions
+// like v0.0.0-20190429141453-4964c97755c6 and mark major versions without go.mod by +incompatible.
+type Version struct {
+	Major int64
+	Minor int64
+	Micro int64
+	// Prerelease contains the dot separated identifiers after the dash, e.g. rc.1, empty for a release
+	Prerelease string
+	// Build contains the dot separated build metadata after the plus, e.g. incompatible. It is ignored for
+	// comparisons.
+	Build string
+}
+
+func (v Version) String() string {
+	str := "v" + strconv.FormatInt(v.Major, 10) + "." + strconv.FormatInt(v.Minor, 10) + "." + strconv.FormatInt(v.Micro, 10)
+	if len(v.Prerelease) > 0 {
+		str += "-" + v.Prerelease
+	}
+	if len(v.Build) > 0 {
+		str += "+" + v.Build
+	}
+	return str
+}
+
+// IsNewer if this version is newer or higher than the other
+func (v Version) IsNewer(other Version) bool {
+	return v.Compare(other) > 0
+}
+
+// Compare returns -1, 0 or +1 if this version is lower, equal or higher than the other. A pre-release is lower
+// than its release and the build metadata is not taken into account, as defined by semver.
+func (v Version) Compare(other Version) int {
+	for _, d := range []int64{v.Major - other.Major, v.Minor - other.Minor, v.Micro - other.Micro} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// comparePrerelease compares the dot separated identifiers. Numeric identifiers are compared numerically and
+// are lower than alphanumeric ones, which are compared lexically. An empty pre-release is a release and higher.
+func comparePrerelease(a string, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case len(a) == 0:
+		return 1
+	case len(b) == 0:
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if cmp := compareIdentifier(as[i], bs[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// compareIdentifier compares a single pre-release identifier. Numbers may exceed int64, so they are compared
+// by length first.
+func compareIdentifier(a string, b string) int {
+	an, bn := isNumeric(a), isNumeric(b)
+	switch {
+	case an && !bn:
+		return -1
+	case !an && bn:
+		return 1
+	case an && len(a) != len(b):
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// isNumeric checks if the identifier only consists of digits
+func isNumeric(str string) bool {
+	for _, r := range str {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(str) > 0
+}
+
+// identifierRegex matches a single pre-release or build identifier
+var identifierRegex = regexp.MustCompile(`^[0-9A-Za-z-]+$`)
+
+// ParseSemanticVersion reads strings like v0.1.2 or 0.1.2, optionally followed by a pre-release like -rc.1
+// and build metadata like +incompatible
+func ParseSemanticVersion(str string) (Version, error) {
+	str = strings.TrimSpace(str)
+	original := str
+	if strings.HasPrefix(str, "v") {
+		str = str[1:]
+	}
+
+	v := Version{}
+	if idx := strings.Index(str, "+"); idx >= 0 {
+		v.Build = str[idx+1:]
+		str = str[:idx]
+		if err := checkIdentifiers(v.Build, false); err != nil {
+			return Version{}, fmt.Errorf("failed to parse version: %s: build metadata: %v", original, err)
+		}
+	}
+	if idx := strings.Index(str, "-"); idx >= 0 {
+		v.Prerelease = str[idx+1:]
+		str = str[:idx]
+		if err := checkIdentifiers(v.Prerelease, true); err != nil {
+			return Version{}, fmt.Errorf("failed to parse version: %s: pre-release: %v", original, err)
+		}
+	}
+
+	tokens := strings.Split(str, ".")
+	if len(tokens) != 3 {
+		return Version{}, fmt.Errorf("failed to parse version: %s", original)
+	}
+	numbers := make([]int64, 3)
+	for i, token := range tokens {
+		if !isNumeric(token) || len(token) > 1 && token[0] == '0' {
+			return Version{}, fmt.Errorf("failed to parse version: %s: invalid number '%s'", original, token)
+		}
+		n, err := strconv.ParseInt(token, 10, 64)
+		if err != nil {
+			return Version{}, fmt.Errorf("failed to parse version: %s: %v", original, err)
+		}
+		numbers[i] = n
+	}
+	v.Major, v.Minor, v.Micro = numbers[0], numbers[1], numbers[2]
+	return v, nil
+}
+
+// checkIdentifiers validates dot separated identifiers. Numeric pre-release identifiers must not have leading
+// zeros.
+func checkIdentifiers(str string, prerelease bool) error {
+	for _, id := range strings.Split(str, ".") {
+		if !identifierRegex.MatchString(id) {
+			return fmt.Errorf("invalid identifier '%s'", id)
+		}
+		if prerelease && isNumeric(id) && len(id) > 1 && id[0] == '0' {
+			return fmt.Errorf("leading zero in '%s'", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSemanticVersion(t *testing.T) {
+	cases := map[string]Version{
+		"0.0.0":                              {},
+		"v1.2.3":                             {Major: 1, Minor: 2, Micro: 3},
+		" v10.20.30 ":                        {Major: 10, Minor: 20, Micro: 30},
+		"v1.2.0-rc.1":                        {Major: 1, Minor: 2, Prerelease: "rc.1"},
+		"1.0.0-alpha-1.x":                    {Major: 1, Prerelease: "alpha-1.x"},
+		"v1.0.0+20130313":                    {Major: 1, Build: "20130313"},
+		"v1.0.0-0.3.7":                       {Major: 1, Prerelease: "0.3.7"},
+		"v2.0.0+incompatible":                {Major: 2, Build: "incompatible"},
+		"v1.0.0-beta+exp.sha.5114f85":        {Major: 1, Prerelease: "beta", Build: "exp.sha.5114f85"},
+		"v0.0.0-20180306012644-bacd9c7ef1dd": {Prerelease: "20180306012644-bacd9c7ef1dd"},
+		"v1.0.0+007":                         {Major: 1, Build: "007"},
+	}
+	for str, expected := range cases {
+		v, err := ParseSemanticVersion(str)
+		if err != nil {
+			t.Fatalf("%s: %v", str, err)
+		}
+		if v != expected {
+			t.Fatalf("%s: expected %+v but got %+v", str, expected, v)
+		}
+		if strings.TrimSpace(v.String()) != "v"+strings.TrimPrefix(strings.TrimSpace(str), "v") {
+			t.Fatalf("%s: unexpected round trip %s", str, v.String())
+		}
+	}
+}
+
+func TestParseSemanticVersionErrors(t *testing.T) {
+	for _, str := range []string{"", "v", "1", "1.2", "1.2.3.4", "01.2.3", "1.02.3", "1.2.03", "-1.2.3", "a.b.c",
+		"1.2.3-", "1.2.3+", "1.2.3-rc..1", "1.2.3-01", "1.2.3-rc.01", "1.2.3-rc_1", "1.2.3+build_1", "1.2.3-rc+",
+		"99999999999999999999.0.0", "v1.2.3 v1.2.4"} {
+		if v, err := ParseSemanticVersion(str); err == nil {
+			t.Fatalf("%q: expected error but got %+v", str, v)
+		}
+	}
+}
+
+func TestVersionOrder(t *testing.T) {
+	// strictly ascending, including the precedence example of semver 2.0
+	ordered := []string{
+		"v0.0.0-20180306012644-bacd9c7ef1dd",
+		"v0.0.0-20210630005230-0f9fa26af87c",
+		"v0.0.1",
+		"v0.1.0",
+		"v0.9.0",
+		"v0.10.0",
+		"v1.0.0-0.3.7",
+		"v1.0.0-alpha",
+		"v1.0.0-alpha.1",
+		"v1.0.0-alpha.beta",
+		"v1.0.0-beta",
+		"v1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"v1.0.0-rc.1",
+		"v1.0.0",
+		"v1.0.5",
+		"v1.2.0-rc.1",
+		"v1.2.0",
+		"v1.2.1-0.20190429141453-4964c97755c6",
+		"v1.2.1",
+		"v1.10.0",
+		"v2.0.0",
+		"v20.10.8+incompatible",
+		"v100.0.0-99999999999999999999",
+		"v100.0.0-100000000000000000000",
+		"v100.0.0",
+	}
+	versions := make([]Version, len(ordered))
+	for i, str := range ordered {
+		v, err := ParseSemanticVersion(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions[i] = v
+	}
+
+	for i, a := range versions {
+		for j, b := range versions {
+			expected := 0
+			switch {
+			case i < j:
+				expected = -1
+			case i > j:
+				expected = 1
+			}
+			if cmp := a.Compare(b); cmp != expected {
+				t.Fatalf("%s compare %s: expected %d but got %d", a, b, expected, cmp)
+			}
+			if a.IsNewer(b) != (i > j) {
+				t.Fatalf("%s newer %s: expected %v", a, b, i > j)
+			}
+		}
+	}
+}
+
+func TestVersionBuildMetadata(t *testing.T) {
+	a := Version{Major: 1, Build: "a"}
+	b := Version{Major: 1, Build: "b"}
+	if a.Compare(b) != 0 || a.IsNewer(b) || b.IsNewer(a) {
+		t.Fatalf("build metadata must not be compared")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// The templates of goup init, see initData for the available values
+
+const initGoupYAMLTemplate = `# The name is used to setup a custom workspace and tools.
+name: {{.Name}}
+
+# The build section defines what and how goup should work
+build:
+  gomobile:
+    # the toolchain section is required to setup a stable gomobile building experience
+    toolchain:
+      go: {{.Toolchain.Go}}
+      ndk: {{.Toolchain.Ndk}}
+      sdk: {{.Toolchain.Sdk}}
+      jdk: {{.Toolchain.Jdk}}
+      gomobile: {{.Toolchain.Gomobile}}
+{{- if .Ios}}
+
+    # The ios section defines how our iOS library is build. This only works on MacOS with XCode installed
+    ios:
+      prefix: {{.Prefix}}
+      out: ./appIOS/{{.Prefix}}.framework
+{{- end}}
+{{- if .Android}}
+
+    # The android section defines how our android build is executed
+    android:
+      javapkg: {{.Javapkg}}
+      out: ./appAndroid/libGo/libs/{{.Package}}.aar
+{{- end}}
+
+    # The modules section defines a list of all local or remote go modules, which should be included in the build.
+    modules:
+      - ./libGo
+
+    # The export section defines all exported packages which are passed to gobind by gomobile.
+    export:
+      - {{.Module}}
+`
+
+const initGoModTemplate = `module {{.Module}}
+
+go 1.12
+`
+
+const initGoPackageTemplate = `// Package {{.Package}} is bound by gomobile. Only use types which are supported by gomobile,
+// see https://godoc.org/golang.org/x/mobile/cmd/gobind
+package {{.Package}}
+
+// Hello returns a greeting, to verify the bindings
+func Hello(name string) string {
+	return "Hello " + name
+}
+`
+
+const initGradleTemplate = `// This module runs goupw and provides the resulting aar. Include it in your settings.gradle by
+// include ':libGo'
+// and depend on it in your app by
+// implementation project(':libGo')
+
+// convert gradle log level to go 0=Debug, 1=Info, 2=Warn, 3=Error with default fallback to 1=Info
+def goLogLevel = [(LogLevel.DEBUG):0, (LogLevel.INFO):1, (LogLevel.WARN):2, (LogLevel.ERROR):3].get(gradle.startParameter.logLevel, 1)
+def proc = "./goupw $goLogLevel".execute(null, project.file("../.."))
+
+proc.waitForProcessOutput(System.out, System.err)
+if (proc.exitValue() != 0) {
+    throw new RuntimeException("GoUp failed to compile, inspect output")
+}
+
+apply plugin: 'com.android.library'
+
+android {
+    compileSdkVersion 28
+
+    defaultConfig {
+        minSdkVersion 15
+        targetSdkVersion 28
+    }
+}
+
+dependencies {
+    api fileTree(dir: 'libs', include: ['*.jar', '*.aar'])
+}
+`
+
+const initXcodeTemplate = `#!/bin/bash
+
+# Add a "Run Script" build phase to your app target, before "Compile Sources", containing
+#   "${SRCROOT}/goup.sh"
+# and add appIOS/{{.Prefix}}.framework to "Frameworks, Libraries, and Embedded Content" after the first build.
+
+set -e
+cd "$(dirname "$0")/.."
+./goupw 1
+`
+
+// wrapperTemplate is the goupw script, see wrapperData for the available values
+const wrapperTemplate = `#!/usr/bin/env bash
+
+# This script bootstraps the GoUp setup and should always be checked into the vcs repository, together with
+# {{.Properties}}. It downloads the declared GoUp version, verifies its SHA-256 checksum and executes it.
+# Do not edit this file, but regenerate it by
+#   goup wrapper -version <version>
+
+set -e
+cd "$(dirname "$0")"
+
+property() {
+    grep "^$1=" "{{.Properties}}" | head -n 1 | cut -d= -f2- | tr -d '\r'
+}
+
+VERSION="$(property version)"
+TARGETS="$(property targets)"
+DISTRIBUTION_URL="$(property distributionUrl)"
+LOG_LEVEL="${1:-0}"
+
+# the checksums belong to this version
+CHECKSUMS_VERSION="{{.Version}}"
+
+checksum() {
+    case "$1" in
+{{- range .Checksums}}
+    {{.Platform}}) echo "{{.Sum}}" ;;
+{{- end}}
+    *) echo "" ;;
+    esac
+}
+
+fail() {
+    echo "goupw: $1" >&2
+    exit 1
+}
+
+case "$(uname -s)" in
+    Darwin) os="darwin" ;;
+    Linux) os="linux" ;;
+    *) fail "unsupported operating system $(uname -s)" ;;
+esac
+
+case "$(uname -m)" in
+    x86_64|amd64) arch="amd64" ;;
+    arm64|aarch64) arch="arm64" ;;
+    i386|i686) arch="386" ;;
+    *) arch="$(uname -m)" ;;
+esac
+osarch="$os-$arch"
+
+if [ "$VERSION" != "$CHECKSUMS_VERSION" ]; then
+    fail "the checksums are made for $CHECKSUMS_VERSION but {{.Properties}} declares $VERSION, run: goup wrapper -version $VERSION"
+fi
+
+expected="$(checksum "$osarch")"
+if [ -z "$expected" ]; then
+    fail "there is no $VERSION binary for $osarch"
+fi
+
+sha256() {
+    if command -v sha256sum >/dev/null 2>&1; then
+        sha256sum "$1" | cut -d' ' -f1
+    else
+        shasum -a 256 "$1" | cut -d' ' -f1
+    fi
+}
+
+GOUPDIR=".goup"
+exec="$GOUPDIR/goup-$VERSION-$osarch"
+
+if [ ! -f "$exec" ] || [ "$(sha256 "$exec")" != "$expected" ]; then
+    echo "Fetching GoUp $VERSION for $osarch"
+    mkdir -p "$GOUPDIR"
+    curl -fsSL "$DISTRIBUTION_URL/$VERSION/$osarch/goup" --output "$exec.tmp"
+    actual="$(sha256 "$exec.tmp")"
+    if [ "$actual" != "$expected" ]; then
+        rm -f "$exec.tmp"
+        fail "checksum mismatch of $DISTRIBUTION_URL/$VERSION/$osarch/goup, expected $expected but got $actual"
+    fi
+    chmod +x "$exec.tmp"
+    mv "$exec.tmp" "$exec"
+fi
+
+"$exec" -version
+
+exec "$exec" -dir "$(pwd)" -loglevel "$LOG_LEVEL" -targets "$TARGETS"
+`
+
+// wrapperPropertiesTemplate declares what goupw executes, see wrapperData for the available values
+const wrapperPropertiesTemplate = `# The GoUp version, which is executed by goupw. Regenerate goupw after changing it, by
+#   goup wrapper -version <version>
+version={{.Version}}
+# The targets to build, e.g. all|gomobile/android|gomobile/ios|gomobile/android:gomobile/ios
+targets={{.Targets}}
+# Where the GoUp binaries are downloaded from, as <distributionUrl>/<version>/<os>-<arch>/goup
+distributionUrl={{.URL}}
+`
@@ -0,0 +1,383 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A ConfigError describes a single problem of a goup.yaml file
+type ConfigError struct {
+	// File is the yaml file
+	File Path
+	// Line is the 1 based line of the problem, 0 if unknown
+	Line int
+	// Column is the 1 based column of the problem, 0 if unknown
+	Column int
+	// Field is the dotted path of the affected key, e.g. build.gomobile.android.out
+	Field string
+	// Message describes the problem
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	sb := &strings.Builder{}
+	if len(e.File) > 0 {
+		sb.WriteString(string(e.File))
+		if e.Line > 0 {
+			sb.WriteString(fmt.Sprintf(":%d:%d", e.Line, e.Column))
+		}
+		sb.WriteString(": ")
+	}
+	if len(e.Field) > 0 {
+		sb.WriteString(e.Field + ": ")
+	}
+	sb.WriteString(e.Message)
+	return sb.String()
+}
+
+// ConfigErrors contains all problems of a goup.yaml file, so that they can be fixed at once
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	tmp := make([]string, 0, len(e))
+	for _, err := range e {
+		tmp = append(tmp, "  "+err.Error())
+	}
+	return fmt.Sprintf("invalid configuration, %d problem(s):\n%s", len(e), strings.Join(tmp, "\n"))
+}
+
+// A yamlPosition is the 1 based line and column of a key
+type yamlPosition struct {
+	// File declares the key, which is an include for merged configurations
+	File   Path
+	Line   int
+	Column int
+}
+
+// javaPackageRegex matches things like com.mycompany.myproject
+var javaPackageRegex = regexp.MustCompile(`^[a-zA-Z_$][a-zA-Z0-9_$]*(\.[a-zA-Z_$][a-zA-Z0-9_$]*)*$`)
+
+// Validate checks the semantics of the configuration and returns all problems, including the unknown keys and
+// type mismatches found while loading, as ConfigErrors with their yaml line and column.
+func (c *GoUpConfiguration) Validate() error {
+	errs := append(ConfigErrors{}, c.decodeErrors...)
+	add := func(field string, format string, args ...interface{}) {
+		pos := c.position(field)
+		errs = append(errs, ConfigError{File: pos.File, Line: pos.Line, Column: pos.Column, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if IsEmpty(c.Name) {
+		add("name", "must not be empty")
+	} else if strings.ContainsAny(c.Name, `/\`) || c.Name == "." || c.Name == ".." {
+		add("name", "must be usable as a directory name, but is '%s'", c.Name)
+	}
+
+	for i, secret := range c.Secrets {
+		if IsEmpty(secret.Name) {
+			add(fmt.Sprintf("secrets[%d]", i), "requires a name")
+		}
+	}
+
+	for _, phase := range []string{phaseToolchain, phaseVendor, phaseBind, phaseBeforeScript, phaseTest} {
+		if _, err := c.Timeouts.Get(phase); err != nil {
+			add("timeouts."+phase, "must be a duration like 90s or 1h30m, but is '%s'", c.Timeouts.value(phase))
+		}
+	}
+
+	switch {
+	case c.Build == nil:
+		add("build", "is missing")
+	case c.Build.Gomobile == nil:
+		add("build.gomobile", "is missing")
+	default:
+		c.Build.Gomobile.validate(add)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Line < errs[j].Line || errs[i].Line == errs[j].Line && errs[i].Column < errs[j].Column
+	})
+	return errs
+}
+
+// validate checks modules, exports and the target sections
+func (b *BuildGomobile) validate(add func(field string, format string, args ...interface{})) {
+	b.validateMode(add)
+
+	if len(b.Modules) == 0 {
+		add("build.gomobile.modules", "at least one module is required")
+	}
+	for i, mod := range b.Modules {
+		if IsEmpty(string(mod)) {
+			add(fmt.Sprintf("build.gomobile.modules[%d]", i), "must not be empty")
+		}
+	}
+
+	if len(b.Export) == 0 {
+		add("build.gomobile.export", "at least one exported package is required")
+	}
+	for i, pkg := range b.Export {
+		if IsEmpty(pkg) {
+			add(fmt.Sprintf("build.gomobile.export[%d]", i), "must not be empty")
+		}
+	}
+
+	if b.Android != nil {
+		if len(b.Android.Out) > 0 && !strings.HasSuffix(string(b.Android.Out), ".aar") {
+			add("build.gomobile.android.out", "must be an .aar file, but is '%s'", string(b.Android.Out))
+		}
+		if len(b.Android.Javapkg) > 0 && !javaPackageRegex.MatchString(b.Android.Javapkg) {
+			add("build.gomobile.android.javapkg", "is not a valid java package name: '%s'", b.Android.Javapkg)
+		}
+		b.Android.validate(add)
+	}
+
+	if b.Ios != nil {
+		out := strings.TrimSuffix(string(b.Ios.Out), "/")
+		if len(out) > 0 && !strings.HasSuffix(out, ".framework") && !strings.HasSuffix(out, ".xcframework") {
+			add("build.gomobile.ios.out", "must be a .framework or .xcframework folder, but is '%s'", string(b.Ios.Out))
+		}
+	}
+}
+
+// locate adds the file and position to a ConfigError, other errors are returned as is
+func (c *GoUpConfiguration) locate(err error) error {
+	cfgErr, ok := err.(ConfigError)
+	if !ok {
+		return err
+	}
+	pos := c.position(cfgErr.Field)
+	cfgErr.File, cfgErr.Line, cfgErr.Column = pos.File, pos.Line, pos.Column
+	return ConfigErrors{cfgErr}
+}
+
+// position returns the position of the field or of its nearest declared parent. Undeclared fields are located
+// in the file itself.
+func (c *GoUpConfiguration) position(field string) yamlPosition {
+	for len(field) > 0 {
+		if pos, ok := c.positions[field]; ok {
+			return pos
+		}
+		idx := strings.LastIndexAny(field, ".[")
+		if idx < 0 {
+			break
+		}
+		field = field[:idx]
+	}
+	return yamlPosition{File: c.file}
+}
+
+// indexNode adds the positions of the keys and list items below the node, which is declared by path
+func indexNode(positions map[string]yamlPosition, file Path, node *yaml.Node, path string) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			indexNode(positions, file, child, path)
+		}
+	case yaml.AliasNode:
+		indexNode(positions, file, node.Alias, path)
+	case yaml.MappingNode:
+		// merged keys are declared at their anchor, unless the mapping declares them itself
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() != "!!merge" {
+				continue
+			}
+			if value.Kind == yaml.SequenceNode {
+				for j := len(value.Content) - 1; j >= 0; j-- {
+					indexNode(positions, file, value.Content[j], path)
+				}
+				continue
+			}
+			indexNode(positions, file, value, path)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				continue
+			}
+			keyPath := joinField(path, key.Value)
+			positions[keyPath] = yamlPosition{File: file, Line: key.Line, Column: key.Column}
+			indexNode(positions, file, value, keyPath)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemPath := fmt.Sprintf("%s[%d]", path, i)
+			positions[itemPath] = yamlPosition{File: file, Line: item.Line, Column: item.Column}
+			indexNode(positions, file, item, itemPath)
+		}
+	}
+}
+
+// decodeNode decodes the node into v, just like yaml.Unmarshal. Instead of failing at the first problem, all unknown
+// keys, duplicate keys and type mismatches are returned at the position of their node. Their file is set by the
+// caller.
+func decodeNode(node *yaml.Node, v reflect.Value) ConfigErrors {
+	errs := ConfigErrors{}
+	decodeValue(node, v, &errs)
+	return errs
+}
+
+// decodeValue decodes the node into the addressable v and appends all problems to errs
+func decodeValue(node *yaml.Node, v reflect.Value, errs *ConfigErrors) {
+	fail := func(n *yaml.Node, format string, args ...interface{}) {
+		*errs = append(*errs, ConfigError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			decodeValue(child, v, errs)
+		}
+		return
+	case yaml.AliasNode:
+		decodeValue(node.Alias, v, errs)
+		return
+	}
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!null" {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	if u, ok := v.Addr().Interface().(yaml.Unmarshaler); ok {
+		if err := u.UnmarshalYAML(node); err != nil {
+			if nested, ok := err.(ConfigErrors); ok {
+				*errs = append(*errs, nested...)
+			} else {
+				fail(node, "%v", err)
+			}
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		decodeValue(node, v.Elem(), errs)
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			fail(node, "expected a mapping, but got %s", nodeKind(node))
+			return
+		}
+		fields := make(map[string]int)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				fields[yamlName(v.Type().Field(i))] = i
+			}
+		}
+		decodeMerges(node, v, errs)
+		declared := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				continue
+			}
+			idx, ok := fields[key.Value]
+			switch {
+			case !ok:
+				fail(key, "unknown key '%s'", key.Value)
+			case declared[key.Value]:
+				fail(key, "duplicate key '%s'", key.Value)
+			default:
+				declared[key.Value] = true
+				decodeValue(value, v.Field(idx), errs)
+			}
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			fail(node, "expected a mapping, but got %s", nodeKind(node))
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		decodeMerges(node, v, errs)
+		declared := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				continue
+			}
+			if declared[key.Value] {
+				fail(key, "duplicate key '%s'", key.Value)
+				continue
+			}
+			declared[key.Value] = true
+			k := reflect.New(v.Type().Key()).Elem()
+			decodeValue(key, k, errs)
+			e := reflect.New(v.Type().Elem()).Elem()
+			decodeValue(value, e, errs)
+			v.SetMapIndex(k, e)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			fail(node, "expected a list, but got %s", nodeKind(node))
+			return
+		}
+		items := reflect.MakeSlice(v.Type(), len(node.Content), len(node.Content))
+		for i, item := range node.Content {
+			decodeValue(item, items.Index(i), errs)
+		}
+		v.Set(items)
+	default:
+		if node.Kind != yaml.ScalarNode {
+			fail(node, "expected a %s, but got %s", v.Kind(), nodeKind(node))
+			return
+		}
+		if err := node.Decode(v.Addr().Interface()); err != nil {
+			fail(node, "expected a %s, but got %s", v.Kind(), nodeKind(node))
+		}
+	}
+}
+
+// decodeMerges decodes the mappings of merge keys like <<: *base into v, before the keys of the mapping itself
+// override them. The first mapping of a merged list wins.
+func decodeMerges(node *yaml.Node, v reflect.Value, errs *ConfigErrors) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			continue
+		}
+		if value.Kind == yaml.SequenceNode {
+			for j := len(value.Content) - 1; j >= 0; j-- {
+				decodeValue(value.Content[j], v, errs)
+			}
+			continue
+		}
+		decodeValue(value, v, errs)
+	}
+}
+
+// nodeKind describes the node for a problem, e.g. a list or the value of a scalar
+func nodeKind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return "'" + node.Value + "'"
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "goup.yaml")
+	err = ioutil.WriteFile(file, []byte(`name: app
+secrets:
+  - name: A
+    fiel: ./a
+build:
+  gomobile:
+    exports:
+      - foo
+    modules:
+    - ./x
+    android:
+      javapkg: com.my-company
+      out: ./lib.jar
+      ldflag: -s
+`), os.ModePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &GoUpConfiguration{}
+	if err := cfg.Load(Path(file)); err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		t.Fatal("expected ConfigErrors but got", err)
+	}
+
+	expected := []string{
+		"4:5: unknown key 'fiel'",
+		"6:3: build.gomobile.export: at least one exported package is required",
+		"7:5: unknown key 'exports'",
+		"12:7: build.gomobile.android.javapkg: is not a valid java package name: 'com.my-company'",
+		"13:7: build.gomobile.android.out: must be an .aar file, but is './lib.jar'",
+		"14:7: unknown key 'ldflag'",
+	}
+	if len(errs) != len(expected) {
+		t.Fatal("expected", len(expected), "problems but got", err)
+	}
+	for i, e := range expected {
+		if !strings.HasSuffix(errs[i].Error(), "goup.yaml:"+e) {
+			t.Fatalf("expected %s but got %s", e, errs[i].Error())
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	data := []byte(`name: x
+# comment
+before_script:
+- echo a
+- |
+  name: not a key
+variables:
+  "QUOTED": 1
+build:
+  gomobile:
+    modules:
+      - ./a
+      - ./b
+    export: [a, {b: c}]
+`)
+	cfg := &GoUpConfiguration{}
+	if err := cfg.parse("goup.yaml", data); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]yamlPosition{
+		"name":                       {File: "goup.yaml", Line: 1, Column: 1},
+		"before_script[0]":           {File: "goup.yaml", Line: 4, Column: 3},
+		"before_script[1]":           {File: "goup.yaml", Line: 5, Column: 3},
+		"variables.QUOTED":           {File: "goup.yaml", Line: 8, Column: 3},
+		"build.gomobile.modules[1]":  {File: "goup.yaml", Line: 13, Column: 9},
+		"build.gomobile.export[0]":   {File: "goup.yaml", Line: 14, Column: 14},
+		"build.gomobile.export[1].b": {File: "goup.yaml", Line: 14, Column: 18},
+	}
+	for k, v := range expected {
+		if cfg.positions[k] != v {
+			t.Fatalf("%s: expected %v but got %v", k, v, cfg.positions[k])
+		}
+	}
+	if len(cfg.positions) != 15 {
+		t.Fatal("unexpected positions", cfg.positions)
+	}
+	if cfg.Variables["QUOTED"] != "1" || len(cfg.Before_script) != 2 || cfg.Before_script[1] != "name: not a key\n" {
+		t.Fatal("unexpected values", cfg)
+	}
+	if len(cfg.decodeErrors) != 1 || cfg.decodeErrors[0].Error() != "goup.yaml:14:17: expected a string, but got a mapping" {
+		t.Fatal("unexpected problems", cfg.decodeErrors)
+	}
+
+	err := (&GoUpConfiguration{}).parse("goup.yaml", []byte("name: x\nbuild: [\n"))
+	if errs, ok := err.(ConfigErrors); !ok || len(errs) != 1 || errs[0].Line != 2 {
+		t.Fatal("expected a syntax error but got", err)
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	data := []byte(`name: app
+name: again
+secrets:
+  - A
+  - name: B
+    optional: yes
+  - name: C
+    fiel: ./c
+timeouts: 5m
+base: &base
+  api: 21
+build:
+  gomobile:
+    android:
+      api: twenty
+      abis: arm64
+      disabled: [true]
+    ios: ~
+profiles:
+  release:
+    gomobile:
+      android:
+        <<: *base
+        javapkg: com.example
+`)
+	cfg := &GoUpConfiguration{}
+	if err := cfg.parse("goup.yaml", data); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"goup.yaml:2:1: duplicate key 'name'",
+		"goup.yaml:8:5: unknown key 'fiel'",
+		"goup.yaml:9:11: expected a mapping, but got '5m'",
+		"goup.yaml:10:1: unknown key 'base'",
+		"goup.yaml:15:12: expected a int, but got 'twenty'",
+		"goup.yaml:16:13: expected a list, but got 'arm64'",
+		"goup.yaml:17:17: expected a bool, but got a list",
+	}
+	if len(cfg.decodeErrors) != len(expected) {
+		t.Fatal("expected", expected, "but got", cfg.decodeErrors)
+	}
+	for i, e := range expected {
+		if cfg.decodeErrors[i].Error() != e {
+			t.Fatalf("expected %s but got %s", e, cfg.decodeErrors[i].Error())
+		}
+	}
+
+	// the valid values are decoded anyway
+	secrets := []Secret{{Name: "A"}, {Name: "B", Optional: true}, {Name: "C"}}
+	release := cfg.Profiles["release"].Gomobile.Android
+	if cfg.Name != "app" || !reflect.DeepEqual(cfg.Secrets, secrets) || cfg.Build.Gomobile.Ios != nil ||
+		release.Api != 21 || release.Javapkg != "com.example" {
+		t.Fatal("unexpected values", cfg)
+	}
+
+	// merged keys are declared at their anchor
+	if pos := cfg.positions["profiles.release.gomobile.android.api"]; pos.Line != 11 || pos.Column != 3 {
+		t.Fatal("unexpected position", pos)
+	}
+}
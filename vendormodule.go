@@ -19,66 +19,13 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
 )
 
-// A Version represents a semver version
-type Version struct {
-	Major int64
-	Minor int64
-	Micro int64
-}
-
-func (v Version) String() string {
-	return "v" + strconv.Itoa(int(v.Major)) + "." + strconv.Itoa(int(v.Minor)) + "." + strconv.Itoa(int(v.Micro))
-}
-
-// IsNewer if this version is newer or higher than the other
-func (v Version) IsNewer(other Version) bool {
-	if v.Major > other.Major {
-		return true
-	}
-	if v.Minor > other.Minor {
-		return true
-	}
-	if v.Micro > other.Micro {
-		return true
-	}
-	return false
-}
-
-// ParseSemanticVersion reads strings like v0.1.2 or 0.1.2
-func ParseSemanticVersion(str string) (Version, error) {
-	str = strings.TrimSpace(str)
-	if strings.HasPrefix(str, "v") {
-		str = str[1:]
-	}
-	msg := "failed to parse version: %s: %v"
-	tokens := strings.Split(str, ".")
-	if len(tokens) != 3 {
-		return Version{}, fmt.Errorf("failed to parse version: %s", str)
-	}
-	major, err := strconv.ParseInt(tokens[0], 10, 64)
-	if err != nil {
-		return Version{}, fmt.Errorf(msg, str, err)
-	}
-	minor, err := strconv.ParseInt(tokens[1], 10, 64)
-	if err != nil {
-		return Version{}, fmt.Errorf(msg, str, err)
-	}
-
-	micro, err := strconv.ParseInt(tokens[2], 10, 64)
-	if err != nil {
-		return Version{}, fmt.Errorf(msg, str, err)
-	}
-
-	return Version{major, minor, micro}, nil
-}
-
-// A VendoredModule represents an entry from modules.txt, as generated by go mod vendor
+// A VendoredModule represents a module from modules.txt, as generated by go mod vendor
 // e.g.
 //    # github.com/worldiety/std v0.0.0-20190429141453-4964c97755c6
+//    ## explicit; go 1.12
 //    github.com/worldiety/std
 type VendoredModule struct {
 	// ModuleName is the actual name of the module
@@ -90,25 +37,35 @@ type VendoredModule struct {
 
 	// Local determines the fully qualified local path
 	Local Path
+
+	// Packages contains the vendored packages of the module
+	Packages []string
+	// Explicit is true, if the module is required by the go.mod of the vendoring module
+	Explicit bool
+	// GoVersion is the go version of the go.mod of the module, if known
+	GoVersion string
+
+	// Replacement is the module path or the directory, which replaces the module, empty if it is not replaced
+	Replacement string
+	// ReplacementVersion is the version of a replacing module, 0.0.0 for a directory
+	ReplacementVersion Version
 }
 
-// ParseModulesTxT parsed the modules.txt, as generated by go mod-vendor.
+// ParseModulesTxT parsed the modules.txt, as generated by go mod vendor. A module header is followed by
+// its annotations (go 1.14+) and by its vendored packages. Headers without a version only record a replacement
+// (go 1.17+), which is not used by any vendored package, and are ignored.
 // Example:
+// # example.com/x v1.0.0 => ../x
+// ## explicit; go 1.17
+// example.com/x
 // # github.com/json-iterator/go v1.1.6
 // github.com/json-iterator/go
-// # github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd
-// github.com/modern-go/concurrent
-// # github.com/modern-go/reflect2 v1.0.1
-// github.com/modern-go/reflect2
 // # golang.org/x/text v0.3.2
+// ## explicit
 // golang.org/x/text/collate
 // golang.org/x/text/language
 // golang.org/x/text/internal/colltab
-// golang.org/x/text/unicode/norm
-// golang.org/x/text/internal/language
-// golang.org/x/text/internal/language/compact
-// golang.org/x/text/transform
-// golang.org/x/text/internal/tag
+// # example.com/x => ../x
 func ParseModulesTxT(fname string) ([]VendoredModule, error) {
 	//there is no modules.txt if there are no dependencies, that is valid
 	if !Path(fname).Exists() {
@@ -118,68 +75,108 @@ func ParseModulesTxT(fname string) ([]VendoredModule, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to parse module.txt: %v", err)
 	}
+	res, err := parseModulesTxT(Path(filepath.Dir(fname)), text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", fname, err)
+	}
+	return res, nil
+}
 
+// parseModulesTxT parses the content of modules.txt, vendorDir is the folder which contains the packages
+func parseModulesTxT(vendorDir Path, text []byte) ([]VendoredModule, error) {
 	res := make([]VendoredModule, 0)
-
-	lines := strings.Split(string(text), "\n")
-	for i := 0; i < len(lines); {
-		line := strings.TrimSpace(lines[i])
-		// skip empty line
-		if len(line) < 1 {
-			i++
-			continue
+	var current *VendoredModule
+	// replacementOnly is set for headers like # example.com/x => ../x
+	replacementOnly := false
+	finish := func() {
+		if current != nil && !replacementOnly {
+			res = append(res, *current)
 		}
+		current = nil
+	}
 
-		var modImportURL string
-		var version Version
-		// is it a comment?
-		if strings.HasPrefix(line, "#") {
-			pathVersionTokens := strings.Split(line, " ")
-			if len(pathVersionTokens) < 2 {
-				fmt.Printf("%s: invalid path-version at line %d: %s", fname, i, lines[i])
-				i++
+	for i, line := range strings.Split(string(text), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case len(line) == 0:
+			continue
+		case strings.HasPrefix(line, "##"):
+			// annotations before the first module, e.g. ## workspace, do not belong to a module
+			if current == nil {
 				continue
 			}
-
-			var versionTokens string
-			for _, token := range pathVersionTokens {
-				if token == "#" {
-					continue
-				}
-				if len(modImportURL) == 0 {
-					modImportURL = token
-				}
-
-				if len(modImportURL) > 0 && strings.HasPrefix(token, "v") {
-					versionTokens = token
+			for _, annotation := range strings.Split(strings.TrimPrefix(line, "##"), ";") {
+				annotation = strings.TrimSpace(annotation)
+				switch {
+				case annotation == "explicit":
+					current.Explicit = true
+				case strings.HasPrefix(annotation, "go "):
+					current.GoVersion = strings.TrimSpace(strings.TrimPrefix(annotation, "go "))
 				}
 			}
-			if strings.Contains(versionTokens, "-") {
-				versionTokens = versionTokens[0:strings.Index(versionTokens, "-")]
+		case strings.HasPrefix(line, "#"):
+			finish()
+			mod, versioned, err := parseModulesTxTHeader(vendorDir, strings.TrimPrefix(line, "#"))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", i+1, err)
 			}
-			if len(versionTokens) == 0 {
-				versionTokens = "v0.0.0"
+			current = &mod
+			replacementOnly = !versioned
+		default:
+			if current == nil {
+				return nil, fmt.Errorf("line %d: package %s without module", i+1, line)
 			}
-
-			version, err = ParseSemanticVersion(versionTokens)
-			if err != nil {
-				i++
-				fmt.Printf("%s: invalid version string at line %d: %s: %v", fname, i, lines[i], err)
-				continue
+			if replacementOnly || strings.ContainsAny(line, " \t") {
+				return nil, fmt.Errorf("line %d: unexpected package %s", i+1, line)
 			}
-			i++
+			current.Packages = append(current.Packages, line)
+		}
+	}
+	finish()
+	return res, nil
+}
+
+// parseModulesTxTHeader parses a header without #, like "example.com/x v1.0.0 => example.com/y v1.0.1" and
+// returns whether the module has a version
+func parseModulesTxTHeader(vendorDir Path, header string) (VendoredModule, bool, error) {
+	parts := strings.SplitN(header, "=>", 2)
+	tokens := strings.Fields(parts[0])
+	if len(tokens) < 1 || len(tokens) > 2 {
+		return VendoredModule{}, false, fmt.Errorf("invalid module '%s'", strings.TrimSpace(parts[0]))
+	}
+
+	mod := VendoredModule{ModuleName: tokens[0], ModuleImport: tokens[0], Local: vendorDir.Add(Path(tokens[0]))}
+	if len(tokens) == 2 {
+		version, err := parseVendoredVersion(tokens[1])
+		if err != nil {
+			return VendoredModule{}, false, err
 		}
+		mod.Version = version
+	}
 
-		modName := lines[i]
-		if len(modImportURL) == 0 {
-			modImportURL = modName
+	if len(parts) == 2 {
+		replacement := strings.Fields(parts[1])
+		if len(replacement) < 1 || len(replacement) > 2 {
+			return VendoredModule{}, false, fmt.Errorf("invalid replacement '%s'", strings.TrimSpace(parts[1]))
+		}
+		mod.Replacement = replacement[0]
+		if len(replacement) == 2 {
+			version, err := parseVendoredVersion(replacement[1])
+			if err != nil {
+				return VendoredModule{}, false, err
+			}
+			mod.ReplacementVersion = version
 		}
-		path := Path(filepath.Dir(fname)).Add(Path(modName))
-		res = append(res, VendoredModule{Version: version, ModuleName: modName, ModuleImport: modImportURL, Local: path})
-		i++
 	}
+	return mod, len(tokens) == 2, nil
+}
 
-	return res, nil
+// parseVendoredVersion parses the version of a modules.txt header, which always starts with v
+func parseVendoredVersion(str string) (Version, error) {
+	if !strings.HasPrefix(str, "v") {
+		return Version{}, fmt.Errorf("invalid version '%s'", str)
+	}
+	return ParseSemanticVersion(str)
 }
 
 // we need to sort the dependencies because the order of a vendored module.txt is not deterministic (at least
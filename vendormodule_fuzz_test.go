@@ -0,0 +1,45 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func FuzzParseModulesTxT(f *testing.F) {
+	files, err := filepath.Glob("testdata/modules/*.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		text, err := ioutil.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(text)
+	}
+
+	f.Fuzz(func(t *testing.T, text []byte) {
+		modules, err := parseModulesTxT("/vendor", text)
+		if err != nil {
+			return
+		}
+		for _, m := range modules {
+			if len(m.ModuleName) == 0 || strings.ContainsAny(m.ModuleName, " \t\n") {
+				t.Fatalf("invalid module name %q", m.ModuleName)
+			}
+			if !m.Local.StartsWith("/vendor") {
+				t.Fatalf("module %s is vendored outside of the vendor folder: %s", m.ModuleName, m.Local)
+			}
+			for _, pkg := range m.Packages {
+				if len(pkg) == 0 || strings.HasPrefix(pkg, "#") || strings.ContainsAny(pkg, " \t\n") {
+					t.Fatalf("invalid package %q of module %s", pkg, m.ModuleName)
+				}
+			}
+		}
+	})
+}
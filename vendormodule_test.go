@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mod is a short hand for the expected modules of the samples
+func mod(name string, version Version, packages ...string) VendoredModule {
+	return VendoredModule{ModuleName: name, ModuleImport: name, Version: version, Local: Path("/vendor").Add(Path(name)), Packages: packages}
+}
+
+// explicit marks the module as explicitly required
+func explicit(m VendoredModule, goVersion string) VendoredModule {
+	m.Explicit = true
+	m.GoVersion = goVersion
+	return m
+}
+
+// replaced sets the replacement of the module
+func replaced(m VendoredModule, replacement string, version Version) VendoredModule {
+	m.Replacement = replacement
+	m.ReplacementVersion = version
+	return m
+}
+
+func TestParseModulesTxT(t *testing.T) {
+	cases := []struct {
+		file     string
+		expected []VendoredModule
+	}{
+		{"go113.txt", []VendoredModule{
+			mod("github.com/json-iterator/go", Version{Major: 1, Minor: 1, Micro: 6}, "github.com/json-iterator/go"),
+			mod("github.com/modern-go/concurrent", Version{Prerelease: "20180306012644-bacd9c7ef1dd"}, "github.com/modern-go/concurrent"),
+			mod("github.com/modern-go/reflect2", Version{Major: 1, Micro: 1}, "github.com/modern-go/reflect2"),
+			mod("golang.org/x/text", Version{Minor: 3, Micro: 2}, "golang.org/x/text/collate", "golang.org/x/text/language",
+				"golang.org/x/text/internal/colltab", "golang.org/x/text/unicode/norm", "golang.org/x/text/internal/language",
+				"golang.org/x/text/internal/language/compact", "golang.org/x/text/transform", "golang.org/x/text/internal/tag"),
+		}},
+		{"go116.txt", []VendoredModule{
+			mod("github.com/davecgh/go-spew", Version{Major: 1, Minor: 1, Micro: 1}, "github.com/davecgh/go-spew/spew"),
+			mod("github.com/pmezard/go-difflib", Version{Major: 1}, "github.com/pmezard/go-difflib/difflib"),
+			explicit(mod("github.com/stretchr/testify", Version{Major: 1, Minor: 7}, "github.com/stretchr/testify/assert"), ""),
+			mod("gopkg.in/yaml.v3", Version{Major: 3, Prerelease: "20200313102051-9f266ea9e77c"}, "gopkg.in/yaml.v3"),
+		}},
+		{"go117.txt", []VendoredModule{
+			replaced(explicit(mod("example.com/x", Version{Major: 1}, "example.com/x"), "1.17"), "../x", Version{}),
+			explicit(mod("github.com/docker/docker", Version{Major: 20, Minor: 10, Micro: 8, Build: "incompatible"}, "github.com/docker/docker/api/types", "github.com/docker/docker/api/types/container"), ""),
+			explicit(mod("github.com/gofrs/flock", Version{Minor: 8, Micro: 1}, "github.com/gofrs/flock"), ""),
+			replaced(explicit(mod("github.com/google/go-cmp", Version{Minor: 5, Micro: 6}, "github.com/google/go-cmp/cmp", "github.com/google/go-cmp/cmp/internal/diff"), "1.8"),
+				"github.com/google/go-cmp", Version{Minor: 5, Micro: 5}),
+			explicit(mod("golang.org/x/sys", Version{Prerelease: "20210630005230-0f9fa26af87c"}, "golang.org/x/sys/unix"), "1.17"),
+			explicit(mod("golang.org/x/mod", Version{Minor: 5}), "1.17"),
+		}},
+		{"go122workspace.txt", []VendoredModule{
+			replaced(explicit(mod("example.com/a", Version{Prerelease: "00010101000000-000000000000"}, "example.com/a"), "1.22"), "./a", Version{}),
+			replaced(explicit(mod("example.com/b", Version{Prerelease: "00010101000000-000000000000"}, "example.com/b", "example.com/b/internal/util"), "1.22"), "./b", Version{}),
+			explicit(mod("golang.org/x/text", Version{Minor: 14}, "golang.org/x/text/language"), "1.18"),
+		}},
+	}
+
+	for _, c := range cases {
+		text, err := ioutil.ReadFile(filepath.Join("testdata", "modules", c.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		modules, err := parseModulesTxT("/vendor", text)
+		if err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		if !reflect.DeepEqual(modules, c.expected) {
+			t.Fatalf("%s: expected\n%+v\nbut got\n%+v", c.file, c.expected, modules)
+		}
+	}
+}
+
+func TestParseModulesTxTErrors(t *testing.T) {
+	cases := map[string]string{
+		"example.com/x\n":                         "line 1: package example.com/x without module",
+		"#\n":                                     "line 1: invalid module ''",
+		"# example.com/x v1.0.0 extra\n":          "line 1: invalid module",
+		"# example.com/x 1.0.0\n":                 "line 1: invalid version '1.0.0'",
+		"# example.com/x v1.0.0 =>\n":             "line 1: invalid replacement ''",
+		"# example.com/x => ../x\nexample.com/x":  "line 2: unexpected package example.com/x",
+		"# example.com/x v1.0.0\nexample.com/x y": "line 2: unexpected package",
+	}
+	for text, msg := range cases {
+		_, err := parseModulesTxT("/vendor", []byte(text))
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%q: expected '%s' but got %v", text, msg, err)
+		}
+	}
+}
+
+func TestParseModulesTxTMissing(t *testing.T) {
+	modules, err := ParseModulesTxT("testdata/modules/missing.txt")
+	if err != nil || modules != nil {
+		t.Fatal("a missing modules.txt means no dependencies, but got", modules, err)
+	}
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !go1.20
+// +build !go1.20
+
+package main
+
+import "os/exec"
+
+// setWaitDelay is not supported before go 1.20, so a killed command is awaited until its children have closed
+// our output pipe as well
+func setWaitDelay(cmd *exec.Cmd) {
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 Torben Schinke
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// wrapperProperties is the name of the properties file, which is read by goupw
+const wrapperProperties = "goupw.properties"
+
+// wrapperPlatforms are the os-arch combinations for which GoUp binaries are published
+var wrapperPlatforms = []string{"darwin-amd64", "darwin-arm64", "linux-amd64", "linux-arm64"}
+
+// wrapperChecksum is the expected SHA-256 of the GoUp binary of a platform
+type wrapperChecksum struct {
+	Platform string
+	Sum      string
+}
+
+// wrapperData contains the values of the wrapper templates
+type wrapperData struct {
+	Version    string
+	Targets    string
+	URL        string
+	Properties string
+	Checksums  []wrapperChecksum
+}
+
+// GenerateWrapper implements the wrapper command, which (re)generates goupw and goupw.properties in the base
+// directory. The checksums of all published platform binaries of the version are embedded into goupw.
+func GenerateWrapper(args *Args, w io.Writer) error {
+	flags := args.FlagSet("wrapper")
+	ver := flags.String("version", "v"+version, "The "+goUp+" version to execute, e.g. v"+version+".")
+	targets := flags.String("targets", "", "The targets to build, e.g. gomobile/android or gomobile/ios. Can be concated by : (default from "+wrapperProperties+" or all)")
+	url := flags.String("distributionUrl", "", "Where the "+goUp+" binaries are downloaded from, as <distributionUrl>/<version>/<os>-<arch>/goup. (default from "+wrapperProperties+" or "+defaultDistributionURL+")")
+	err := args.Parse(flags)
+	if err != nil {
+		return err
+	}
+	if err := noArgs(flags); err != nil {
+		return err
+	}
+
+	// keep the declarations of an existing wrapper
+	properties, err := readProperties(args.BaseDir.Child(wrapperProperties))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if IsEmpty(*targets) {
+		*targets = properties["targets"]
+	}
+	if IsEmpty(*targets) {
+		*targets = "all"
+	}
+	if IsEmpty(*url) {
+		*url = properties["distributionUrl"]
+	}
+	if IsEmpty(*url) {
+		*url = defaultDistributionURL
+	}
+
+	data := wrapperData{Version: *ver, Targets: *targets, URL: strings.TrimSuffix(*url, "/"), Properties: wrapperProperties}
+	data.Checksums, err = fetchChecksums(data.URL, data.Version, wrapperPlatforms)
+	if err != nil {
+		return err
+	}
+	return writeWrapper(args.BaseDir, data, w)
+}
+
+// writeWrapper writes goupw and its properties into dir
+func writeWrapper(dir Path, data wrapperData, w io.Writer) error {
+	err := writeTemplate(dir.Child("goupw"), wrapperTemplate, data, 0755)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "created %s\n", dir.Child("goupw"))
+
+	err = writeTemplate(dir.Child(wrapperProperties), wrapperPropertiesTemplate, data, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "created %s\n", dir.Child(wrapperProperties))
+	return nil
+}
+
+// fetchChecksums downloads the binary of each platform and calculates its SHA-256. Platforms without a
+// binary are omitted, but at least one must exist.
+func fetchChecksums(url string, version string, platforms []string) ([]wrapperChecksum, error) {
+	var res []wrapperChecksum
+	for _, platform := range platforms {
+		binary := url + "/" + version + "/" + platform + "/goup"
+		logger.Debug(Fields{"action": "downloading", "url": binary})
+		sum, err := downloadChecksum(binary)
+		if err != nil {
+			return nil, err
+		}
+		if len(sum) == 0 {
+			logger.Warn(Fields{"platform": platform, "msg": "not published", "url": binary})
+			continue
+		}
+		res = append(res, wrapperChecksum{Platform: platform, Sum: sum})
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%s %s is not published at %s", goUp, version, url)
+	}
+	return res, nil
+}
+
+// downloadChecksum returns the SHA-256 of the content of url or the empty string if it does not exist
+func downloadChecksum(url string) (string, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusForbidden {
+		return "", nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, res.Status)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// readProperties parses key=value lines, ignoring empty lines and # comments
+func readProperties(file Path) (map[string]string, error) {
+	f, err := os.Open(file.String())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) == 2 {
+			res[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+		}
+	}
+	return res, scanner.Err()
+}
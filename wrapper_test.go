@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWrapper(t *testing.T) {
+	server := newDistributionServer("v1.2.3")
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "goup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	must(ioutil.WriteFile(Path(dir).Child(wrapperProperties).String(), []byte("version=v1.0.0\ntargets=gomobile/ios\n"), os.ModePerm))
+
+	args := &Args{BaseDir: Path(dir), CommandArgs: []string{"-version", "v1.2.3", "-distributionUrl", server.URL}}
+	if err := GenerateWrapper(args, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := ioutil.ReadFile(Path(dir).Child("goupw").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`CHECKSUMS_VERSION="v1.2.3"`,
+		// sha256 of "linux" and "darwin"
+		`linux-amd64) echo "caf90169eefa5f807d577486b9f795ab86ae2983c5c20806cff959117e90af18" ;;`,
+		`darwin-arm64) echo "26ce1a1580f693873b6268fef54c5f0d0607f2896cad02ce2894c0c899a11575" ;;`,
+	} {
+		if !strings.Contains(string(script), expected) {
+			t.Fatal("expected", expected, "in\n", string(script))
+		}
+	}
+	if strings.Contains(string(script), "linux-arm64)") {
+		t.Fatal("unpublished platforms must be omitted")
+	}
+
+	properties, err := readProperties(Path(dir).Child(wrapperProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if properties["version"] != "v1.2.3" || properties["targets"] != "gomobile/ios" || properties["distributionUrl"] != server.URL {
+		t.Fatal("unexpected properties", properties)
+	}
+
+	args.CommandArgs = []string{"-version", "v9.9.9", "-distributionUrl", server.URL}
+	if err := GenerateWrapper(args, ioutil.Discard); err == nil {
+		t.Fatal("expected an error for an unpublished version")
+	}
+}
+
+// newDistributionServer publishes fake linux-amd64 and darwin-arm64 binaries of the given version
+func newDistributionServer(version string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/" + version + "/linux-amd64/goup":
+			_, _ = w.Write([]byte("linux"))
+		case "/" + version + "/darwin-arm64/goup":
+			_, _ = w.Write([]byte("darwin"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}